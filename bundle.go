@@ -0,0 +1,373 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/playlist"
+)
+
+const manifestFile = "manifest.json"
+
+// lastFetchFile is kept in SaveLocation, to tell which meeting was saved there last
+const lastFetchFile = ".last-fetch.json"
+
+// lastFetch is the meeting saved into a folder, and its files
+type lastFetch struct {
+	Meeting string
+	Week    string
+	Files   []string
+}
+
+// recordFetch notes that meeting m of the week of c.Date was saved into SaveLocation as items
+func (c *Config) recordFetch(m string, items []playlist.Item) error {
+	f := lastFetch{Meeting: m, Week: WeekOf(c.Date).Format("2006-01-02")}
+	for _, it := range items {
+		if it.Include {
+			f.Files = append(f.Files, it.Name)
+		}
+	}
+	return c.writeLastFetch(f)
+}
+
+func (c *Config) writeLastFetch(f lastFetch) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.SaveLocation, lastFetchFile), data, 0644)
+}
+
+func (c *Config) readLastFetch() (f lastFetch, err error) {
+	data, err := os.ReadFile(filepath.Join(c.SaveLocation, lastFetchFile))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &f)
+	return
+}
+
+// manifest describes the contents of an exported meeting bundle
+type manifest struct {
+	Meeting string `json:",omitempty"`
+	Date    string `json:",omitempty"`
+	Created time.Time
+	Files   []bundledFile
+}
+
+type bundledFile struct {
+	Name     string
+	Size     int64
+	Checksum string
+}
+
+type bundleWriter interface {
+	add(name string, size int64, r io.Reader) error
+	Close() error
+}
+
+type zipBundle struct {
+	*zip.Writer
+}
+
+func (z zipBundle) add(name string, size int64, r io.Reader) error {
+	w, err := z.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+type tarBundle struct {
+	*tar.Writer
+}
+
+func (t tarBundle) add(name string, size int64, r io.Reader) error {
+	err := t.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    size,
+		ModTime: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(t, r)
+	return err
+}
+
+// exportBundle packages the files of meeting f in SaveLocation into a zip or tar archive at out;
+// everything in SaveLocation when f has no files. Links are replaced by the files they point to
+// and the playlist only uses relative paths.
+func (c *Config) exportBundle(out string, f lastFetch) (err error) {
+	entries, err := os.ReadDir(c.SaveLocation)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]bool)
+	for _, name := range f.Files {
+		files[name] = true
+	}
+
+	archive, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := archive.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var bundle bundleWriter
+	switch strings.ToLower(filepath.Ext(out)) {
+	case ".tar":
+		bundle = tarBundle{tar.NewWriter(archive)}
+	case ".zip":
+		bundle = zipBundle{zip.NewWriter(archive)}
+	default:
+		return errors.New("bundle must be a .zip or .tar file")
+	}
+
+	m := manifest{
+		Meeting: f.Meeting,
+		Date:    f.Week,
+		Created: time.Now(),
+	}
+
+	addFile := func(name string, size int64, r io.Reader) error {
+		logrus.Infof("adding %s to bundle", name)
+		h := md5.New()
+		if err := bundle.add(name, size, io.TeeReader(r, h)); err != nil {
+			return err
+		}
+		m.Files = append(m.Files, bundledFile{
+			Name:     name,
+			Size:     size,
+			Checksum: fmt.Sprintf("%x", h.Sum(nil)),
+		})
+		return nil
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") || name == playlist.File || name == manifestFile {
+			continue
+		}
+		if len(files) > 0 && !files[name] {
+			continue
+		}
+
+		path := filepath.Join(c.SaveLocation, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			logrus.Warnf("skipping %s: %v", name, err)
+			continue
+		}
+		if info.IsDir() {
+			continue
+		}
+
+		media, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = addFile(name, info.Size(), media)
+		media.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if m3u, err := os.ReadFile(filepath.Join(c.SaveLocation, playlist.File)); err == nil {
+		m3u = relativePlaylist(m3u)
+		if err := addFile(playlist.File, int64(len(m3u)), bytes.NewReader(m3u)); err != nil {
+			return err
+		}
+	}
+
+	manifestJSON, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := bundle.add(manifestFile, int64(len(manifestJSON)), bytes.NewReader(manifestJSON)); err != nil {
+		return err
+	}
+
+	return bundle.Close()
+}
+
+// relativePlaylist strips the directories from every entry of an m3u playlist
+func relativePlaylist(playlist []byte) []byte {
+	lines := strings.Split(string(playlist), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines[i] = filepath.Base(line)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// importBundle unpacks a bundle made by exportBundle into SaveLocation and
+// checks every file against the bundle's manifest
+func (c *Config) importBundle(path string) (*manifest, error) {
+	if c.PurgeSaveDir {
+		logrus.Info("Deleting all files in " + c.SaveLocation)
+		if err := playlist.RemoveContents(c.SaveLocation, isMeetingFolder); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	if err := createDirIfNotExist(c.SaveLocation); err != nil {
+		return nil, err
+	}
+
+	checksums := make(map[string]string)
+	var manifestJSON []byte
+
+	extract := func(name string, r io.Reader) error {
+		name = filepath.Base(name)
+		if name == "." || name == ".." || name == string(filepath.Separator) {
+			return nil
+		}
+		if name == manifestFile {
+			var err error
+			manifestJSON, err = io.ReadAll(r)
+			return err
+		}
+
+		logrus.Infof("unpacking %s", name)
+		h := md5.New()
+		dst := filepath.Join(c.SaveLocation, name)
+		out, err := os.Create(dst + ".tmp")
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(out, io.TeeReader(r, h))
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err == nil {
+			err = os.Rename(dst+".tmp", dst)
+		}
+		if err != nil {
+			os.Remove(dst + ".tmp")
+			return err
+		}
+
+		checksums[name] = fmt.Sprintf("%x", h.Sum(nil))
+		return nil
+	}
+
+	var err error
+	if strings.ToLower(filepath.Ext(path)) == ".tar" {
+		err = extractTar(path, extract)
+	} else {
+		err = extractZip(path, extract)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if manifestJSON == nil {
+		return nil, errors.New("bundle has no " + manifestFile)
+	}
+	m := new(manifest)
+	if err := json.Unmarshal(manifestJSON, m); err != nil {
+		return nil, err
+	}
+
+	var bad []string
+	for _, f := range m.Files {
+		if checksums[f.Name] != f.Checksum {
+			bad = append(bad, f.Name)
+			continue
+		}
+		if f.Name == playlist.File {
+			continue
+		}
+		if err := cache.Record(c.SaveLocation, cache.File{Name: f.Name, Checksum: f.Checksum}); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	if len(bad) > 0 {
+		return m, fmt.Errorf("missing or corrupt files in bundle: %s", strings.Join(bad, ", "))
+	}
+
+	// the bundle can be exported again like a fetch
+	imported := lastFetch{Meeting: m.Meeting, Week: m.Date}
+	for _, f := range m.Files {
+		if f.Name != playlist.File {
+			imported.Files = append(imported.Files, f.Name)
+		}
+	}
+	if err := c.writeLastFetch(imported); err != nil {
+		logrus.Warn(err)
+	}
+
+	return m, nil
+}
+
+func extractZip(path string, extract func(string, io.Reader) error) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		err = extract(f.Name, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractTar(path string, extract func(string, io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tar.NewReader(f)
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extract(hdr.Name, r); err != nil {
+			return err
+		}
+	}
+}
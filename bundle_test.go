@@ -0,0 +1,69 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"meeting-media/playlist"
+)
+
+func TestExportLastFetch(t *testing.T) {
+	c := newTestConfig(t, newTestCDN(t))
+	c.OutputMode = COPY
+	if err := c.fetchMeetingStuff(context.Background(), MM); err != nil {
+		t.Fatal(err)
+	}
+	// left over from the meeting before
+	if err := os.WriteFile(filepath.Join(c.SaveLocation, "sjjm_E_001_r720P.mp4"), []byte("old song"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := c.exportCommand([]string{out}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.exportCommand([]string{"-meeting", WM, out}); err == nil || !strings.Contains(err.Error(), "not WM") {
+		t.Errorf("exporting the other meeting: %v", err)
+	}
+
+	r, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var names []string
+	var m manifest
+	for _, f := range r.File {
+		names = append(names, f.Name)
+		if f.Name != manifestFile {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(rc).Decode(&m)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sort.Strings(names)
+
+	want := []string{"1102023302_univ_lsr_lg.jpg", "doc_502026100_1_r720P.mp4", manifestFile, "mwb_E_202609_01.jpg",
+		"mwbv_E_202609_2_r720P.mp4", playlist.File, "sjjm_E_076_r720P.mp4", "sjjm_E_077_r720P.mp4", "sjjm_E_078_r720P.mp4"}
+	sort.Strings(want)
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("bundle has %v, want %v", names, want)
+	}
+	if m.Meeting != MM || m.Date != "2026-09-07" {
+		t.Errorf("bundle of %s %s", m.Meeting, m.Date)
+	}
+}
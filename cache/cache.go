@@ -0,0 +1,279 @@
+// Package cache downloads media into a folder, resuming broken downloads, and keeps an index of
+// their checksums there, so everything is downloaded once and can be found again offline.
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cdn"
+)
+
+var ErrNotCached = errors.New("not in the cache")
+
+// DefaultStallTimeout is how long a download may go without reading anything
+const DefaultStallTimeout = time.Minute
+
+// Cache keeps downloads in Dir, and reports them to Progress
+type Cache struct {
+	Dir          string
+	CDN          *cdn.Client
+	Progress     *Progress
+	DryRun       bool          // fake downloading media, which are not cached; publications are still downloaded
+	StallTimeout time.Duration // a download that reads nothing for this long fails; 0 is DefaultStallTimeout
+}
+
+// File is a download, or a picture taken from a publication
+type File struct {
+	Name     string
+	Payload  []byte
+	URL      string
+	Checksum string
+	Key      string // what was downloaded, so it can be found offline
+}
+
+// Get reads a cached file, making sure it is intact
+func (c *Cache) Get(filename, checksum string) ([]byte, error) {
+	payload, err := os.ReadFile(filepath.Join(c.Dir, filename))
+	if err != nil {
+		return nil, err
+	}
+
+	if !ValidChecksum(checksum, payload) {
+		return nil, errors.New("invalid checksum on cached file")
+	}
+
+	logrus.Infof("using cache for %s", filename)
+	return payload, err
+}
+
+// Put writes f into the cache and records it in the index
+func (c *Cache) Put(f File) error {
+	os.MkdirAll(c.Dir, 0777)
+	err := os.WriteFile(filepath.Join(c.Dir, f.Name), f.Payload, 0644)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("caching %s", f.Name)
+	return Record(c.Dir, f)
+}
+
+// Fetch downloads f unless it is cached already, and makes sure the index knows it by f.Key
+func (c *Cache) Fetch(ctx context.Context, f File, filesize int) (err error) {
+	if _, err = c.Get(f.Name, f.Checksum); err == nil {
+		return Record(c.Dir, f)
+	}
+
+	if f.Payload, err = c.downloadMedia(ctx, f.URL, filesize, f.Checksum); err != nil {
+		return err
+	}
+	if c.DryRun {
+		// nothing was downloaded, so there is nothing to cache or record
+		return nil
+	}
+	return c.Put(f)
+}
+
+// Refetch downloads a cached file again from the URL it was first fetched from
+func (c *Cache) Refetch(ctx context.Context, name string, entry IndexEntry) error {
+	if entry.URL == "" {
+		return errors.New("no download URL known for " + name)
+	}
+
+	logrus.Infof("refetching %s", name)
+	payload, err := c.downloadMedia(ctx, entry.URL, 0, entry.Checksum)
+	if err != nil {
+		c.Progress.Fail(name, err)
+		return err
+	}
+	c.Progress.Finish(name)
+
+	return c.Put(File{
+		Name:     name,
+		Payload:  payload,
+		URL:      entry.URL,
+		Checksum: entry.Checksum,
+	})
+}
+
+func (c *Cache) downloadMedia(ctx context.Context, url string, filesize int, checksum string) (payload []byte, err error) {
+	if c.DryRun {
+		logrus.Debug("Mock downloadMedia:", url)
+		return
+	}
+
+	logrus.Debug("downloading media " + url)
+	return c.Download(ctx, url, int64(filesize), checksum)
+}
+
+// Download fetches url and checks it against checksum. What has been read is
+// kept in a .part file in the cache, so a cancelled, broken or stalled download is
+// resumed the next time instead of starting over.
+func (c *Cache) Download(ctx context.Context, url string, size int64, checksum string) ([]byte, error) {
+	if c.CDN.Offline {
+		return nil, cdn.ErrOffline
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	name := filepath.Base(url)
+	if err := os.MkdirAll(c.Dir, 0777); err != nil {
+		return nil, err
+	}
+	partName := filepath.Join(c.Dir, name+".part")
+
+	part, err := os.OpenFile(partName, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := part.Seek(0, io.SeekEnd)
+	if err != nil {
+		part.Close()
+		return nil, err
+	}
+
+	header := make(http.Header)
+	if offset > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := c.CDN.Get(ctx, url, header)
+	if err != nil {
+		part.Close()
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		logrus.Infof("resuming %s after %d bytes", name, offset)
+	case http.StatusOK:
+		offset = 0
+		if err = part.Truncate(0); err == nil {
+			_, err = part.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			part.Close()
+			return nil, err
+		}
+	default:
+		part.Close()
+		os.Remove(partName)
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	c.Progress.Start(name, size)
+	c.Progress.ResumeAt(offset)
+
+	body := c.stallTimer(resp.Body, cancel)
+	_, err = io.Copy(part, io.TeeReader(body, c.Progress))
+	body.timer.Stop()
+	if cerr := part.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logrus.Infof("keeping partial download of %s", name)
+		if body.stalled() {
+			return nil, fmt.Errorf("download of %s stalled for %s", url, body.timeout)
+		}
+		return nil, fmt.Errorf("error reading data from %s: %w", url, err)
+	}
+
+	payload, err := os.ReadFile(partName)
+	os.Remove(partName)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ValidChecksum(checksum, payload) {
+		return nil, errors.New("invalid checksum for " + name)
+	}
+
+	return payload, nil
+}
+
+// stallReader stops a download by cancelling its request when nothing is read for timeout
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	fired   int32
+}
+
+func (c *Cache) stallTimer(r io.Reader, cancel context.CancelFunc) *stallReader {
+	s := &stallReader{r: r, timeout: c.StallTimeout}
+	if s.timeout <= 0 {
+		s.timeout = DefaultStallTimeout
+	}
+	s.timer = time.AfterFunc(s.timeout, func() {
+		atomic.StoreInt32(&s.fired, 1)
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(b []byte) (int, error) {
+	n, err := s.r.Read(b)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+func (s *stallReader) stalled() bool {
+	return atomic.LoadInt32(&s.fired) == 1
+}
+
+// Image gets a small image, like the thumbnail of a video, and keeps it in the cache by its url.
+// The API gives no checksums for these, so the one recorded when it was downloaded is used,
+// and they aren't reported to Progress.
+func (c *Cache) Image(ctx context.Context, url string) ([]byte, error) {
+	if f, err := c.Find(url, ""); err == nil {
+		return c.Get(f.Name, f.Checksum)
+	}
+
+	resp, err := c.CDN.Get(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	payload, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading data from %s: %w", url, err)
+	}
+	return payload, c.Put(File{Name: filepath.Base(url), Payload: payload, URL: url, Key: url})
+}
+
+// Find finds the intact cached file that was downloaded for key.
+// Files cached before keys were recorded can be found by their name with fallback.
+func (c *Cache) Find(key, fallback string) (File, error) {
+	index, err := ReadIndex(c.Dir)
+	if err != nil {
+		return File{}, err
+	}
+
+	for _, name := range SortedNames(index) {
+		e := index[name]
+		if e.Key != key && name != fallback {
+			continue
+		}
+		if problem := CheckFile(filepath.Join(c.Dir, name), e.Checksum); problem != "" {
+			return File{}, fmt.Errorf("cached %s is %s", name, problem)
+		}
+		return File{Name: name, URL: e.URL, Checksum: e.Checksum, Key: key}, nil
+	}
+
+	return File{}, ErrNotCached
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"meeting-media/cdn"
+	"meeting-media/internal/jwtest"
+)
+
+func TestDownloadStalled(t *testing.T) {
+	// sends the start of the file, then nothing until the client gives up
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "100")
+		w.Write([]byte("0123456789"))
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	client, err := cdn.NewHTTPClient(cdn.Settings{ConnectTimeout: "5s", RetryWaitMin: "1s", RetryWaitMax: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Cache{
+		Dir:          t.TempDir(),
+		CDN:          &cdn.Client{HTTP: client},
+		Progress:     NewProgress(TerminalReporter{W: io.Discard}),
+		StallTimeout: 100 * time.Millisecond,
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Download(context.Background(), server.URL+"/video.mp4", 100, "")
+		done <- err
+	}()
+	select {
+	case err = <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("stalled download never gave up")
+	}
+	if err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Errorf("stalled download: %v", err)
+	}
+
+	// what was read is kept, to resume from
+	part, err := os.ReadFile(filepath.Join(c.Dir, "video.mp4.part"))
+	if err != nil || string(part) != "0123456789" {
+		t.Errorf("partial download %q: %v", part, err)
+	}
+}
+
+func TestImage(t *testing.T) {
+	server := jwtest.NewCDN("E")
+	defer server.Close()
+	url, _ := server.AddFile("sjjm_univ_076_lsr_lg.jpg", []byte("thumbnail"))
+
+	client, err := cdn.NewHTTPClient(cdn.Settings{ConnectTimeout: "5s", RetryWaitMin: "1s", RetryWaitMax: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient.Transport = server.Transport()
+	c := &Cache{Dir: t.TempDir(), CDN: &cdn.Client{HTTP: client}}
+
+	if image, err := c.Image(context.Background(), url); err != nil || string(image) != "thumbnail" {
+		t.Fatalf("downloaded image %q: %v", image, err)
+	}
+
+	// the second time it comes from the cache, offline too
+	c.CDN.Offline = true
+	if image, err := c.Image(context.Background(), url); err != nil || string(image) != "thumbnail" {
+		t.Errorf("cached image %q: %v", image, err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("%d requests for one image", n)
+	}
+}
+
+func TestRecordConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := Record(dir, File{Name: fmt.Sprintf("%d.jpg", i), Payload: []byte{byte(i)}}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	index, err := ReadIndex(dir)
+	if err != nil || len(index) != 20 {
+		t.Errorf("index of %d files: %v", len(index), err)
+	}
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// IndexFile is kept in the cache and in every folder media are saved to
+const IndexFile = ".checksums.json"
+
+// IndexEntry is what the index knows about a file: its checksum, where it was downloaded from,
+// and for media what was downloaded
+type IndexEntry struct {
+	Checksum string
+	URL      string `json:",omitempty"`
+	Key      string `json:",omitempty"`
+}
+
+// ReadIndex reads the index of dir by file name; a folder without one has an empty index
+func ReadIndex(dir string) (map[string]IndexEntry, error) {
+	index := make(map[string]IndexEntry)
+	data, err := os.ReadFile(filepath.Join(dir, IndexFile))
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return index, json.Unmarshal(data, &index)
+}
+
+// WriteIndex replaces the index of dir; it is written to a temporary file first, so it is never left half written
+func WriteIndex(dir string, index map[string]IndexEntry) error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(dir, IndexFile+"-")
+	if err != nil {
+		return err
+	}
+	if err = temp.Chmod(0644); err == nil {
+		_, err = temp.Write(data)
+	}
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(dir, IndexFile))
+}
+
+// recordMu keeps files that are downloaded at the same time, like thumbnails, from being
+// left out of an index that is read and written again by each of them
+var recordMu sync.Mutex
+
+// Record adds f to the index in dir, using the checksum from the API when there is one
+func Record(dir string, f File) error {
+	recordMu.Lock()
+	defer recordMu.Unlock()
+
+	index, err := ReadIndex(dir)
+	if err != nil {
+		return err
+	}
+
+	checksum := f.Checksum
+	if checksum == "" {
+		checksum = fmt.Sprintf("%x", md5.Sum(f.Payload))
+	}
+	index[f.Name] = IndexEntry{Checksum: checksum, URL: f.URL, Key: f.Key}
+
+	return WriteIndex(dir, index)
+}
+
+// FileChecksum is the md5 checksum of the file at path, like the API gives them
+func FileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// CheckFile returns a description of what is wrong with path, or "" if it matches checksum
+func CheckFile(path, checksum string) string {
+	sum, err := FileChecksum(path)
+	if os.IsNotExist(err) {
+		return "missing"
+	}
+	if err != nil {
+		return err.Error()
+	}
+	if sum != checksum {
+		return "corrupt"
+	}
+	return ""
+}
+
+func ValidChecksum(checksum string, payload []byte) bool {
+	if checksum != fmt.Sprintf("%x", md5.Sum(payload)) {
+		return false
+	}
+	return true
+}
+
+// SortedNames lists the files of an index in order
+func SortedNames(index map[string]IndexEntry) []string {
+	names := make([]string, 0, len(index))
+	for name := range index {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
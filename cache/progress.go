@@ -0,0 +1,207 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// kinds of Event
+const (
+	EventStart  = "start"
+	EventBytes  = "bytes"
+	EventFinish = "finish"
+	EventError  = "error"
+)
+
+// Event is sent to a Reporter whenever an item of a fetch changes
+type Event struct {
+	Event  string `json:"event"`
+	Item   string `json:"item"`
+	Read   int64  `json:"read,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+	Done   int    `json:"done"`
+	Failed int    `json:"failed,omitempty"`
+	Total  int    `json:"total"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ItemFraction is how much of the current item has been read, if its size is known
+func (e Event) ItemFraction() float64 {
+	if e.Event == EventFinish {
+		return 1
+	}
+	if e.Size <= 0 {
+		return 0
+	}
+	return float64(e.Read) / float64(e.Size)
+}
+
+// Finished counts the items that are over, whether they were fetched or failed
+func (e Event) Finished() int {
+	return e.Done + e.Failed
+}
+
+// TotalFraction is how much of the whole fetch is done
+func (e Event) TotalFraction() float64 {
+	if e.Total == 0 {
+		return 0
+	}
+	done := float64(e.Finished())
+	if e.Event != EventFinish && e.Event != EventError {
+		done += e.ItemFraction()
+	}
+	return done / float64(e.Total)
+}
+
+type Reporter interface {
+	Report(e Event)
+}
+
+// Progress keeps track of a fetch and passes events on to a reporter.
+// It is an io.Writer, so a download can be teed into it to count bytes.
+type Progress struct {
+	reporter Reporter
+
+	mu       sync.Mutex
+	item     string
+	read     int64
+	reported int64
+	size     int64
+	done     int
+	failed   int
+	total    int
+}
+
+func NewProgress(r Reporter) *Progress {
+	return &Progress{reporter: r}
+}
+
+// Reset starts a new fetch of total items
+func (p *Progress) Reset(total int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done, p.failed, p.total = 0, 0, total
+}
+
+// Expect adds n items to the fetch, for what is only found while it runs
+func (p *Progress) Expect(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.total += n
+}
+
+// Start begins downloading item, which is size bytes or 0 when that is unknown
+func (p *Progress) Start(item string, size int64) {
+	p.mu.Lock()
+	p.item, p.size, p.read, p.reported = item, size, 0, 0
+	e := p.event(EventStart)
+	p.mu.Unlock()
+	p.reporter.Report(e)
+}
+
+// ResumeAt counts n bytes of the current item as read already
+func (p *Progress) ResumeAt(n int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.read, p.reported = n, n
+}
+
+func (p *Progress) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	p.read += int64(len(b))
+
+	// report every percent, or every 256kB when the size is unknown
+	step := p.size / 100
+	if step <= 0 {
+		step = 256 << 10
+	}
+	if p.read-p.reported < step && p.read != p.size {
+		p.mu.Unlock()
+		return len(b), nil
+	}
+	p.reported = p.read
+	e := p.event(EventBytes)
+	p.mu.Unlock()
+
+	p.reporter.Report(e)
+	return len(b), nil
+}
+
+// Finish marks the current item as done; items that needed no download are finished without starting them
+func (p *Progress) Finish(item string) {
+	p.mu.Lock()
+	p.setItem(item)
+	p.done++
+	p.countFinished()
+	e := p.event(EventFinish)
+	p.mu.Unlock()
+	p.reporter.Report(e)
+}
+
+// Fail reports that item could not be fetched
+func (p *Progress) Fail(item string, err error) {
+	p.mu.Lock()
+	p.setItem(item)
+	p.failed++
+	p.countFinished()
+	e := p.event(EventError)
+	e.Error = err.Error()
+	p.mu.Unlock()
+	p.reporter.Report(e)
+}
+
+// countFinished makes room in the total for items that weren't expected
+func (p *Progress) countFinished() {
+	if p.done+p.failed > p.total {
+		p.total = p.done + p.failed
+	}
+}
+
+func (p *Progress) setItem(item string) {
+	if item != p.item {
+		p.item, p.size, p.read, p.reported = item, 0, 0, 0
+	}
+}
+
+func (p *Progress) event(kind string) Event {
+	return Event{
+		Event:  kind,
+		Item:   p.item,
+		Read:   p.read,
+		Size:   p.size,
+		Done:   p.done,
+		Failed: p.failed,
+		Total:  p.total,
+	}
+}
+
+// TerminalReporter draws a single status line on W, for use on the command line
+type TerminalReporter struct {
+	W io.Writer
+}
+
+func (t TerminalReporter) Report(e Event) {
+	switch e.Event {
+	case EventFinish:
+		fmt.Fprintf(t.W, "\r\033[K[%d/%d] %s done\n", e.Finished(), e.Total, e.Item)
+	case EventError:
+		fmt.Fprintf(t.W, "\r\033[K[%d/%d] %s failed: %s\n", e.Finished(), e.Total, e.Item, e.Error)
+	default:
+		fmt.Fprintf(t.W, "\r\033[K[%d/%d] %s %3.0f%%", e.Finished(), e.Total, e.Item, e.ItemFraction()*100)
+	}
+}
+
+// JSONReporter writes every event as a line of JSON
+type JSONReporter struct {
+	enc *json.Encoder
+}
+
+func NewJSONReporter(w io.Writer) JSONReporter {
+	return JSONReporter{json.NewEncoder(w)}
+}
+
+func (j JSONReporter) Report(e Event) {
+	j.enc.Encode(e)
+}
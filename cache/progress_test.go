@@ -0,0 +1,32 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+)
+
+type lastEvent struct{ e *Event }
+
+func (l lastEvent) Report(e Event) { *l.e = e }
+
+func TestProgressWithFailures(t *testing.T) {
+	var last Event
+	p := NewProgress(lastEvent{&last})
+	p.Reset(3)
+	p.Finish("a.mp4")
+	p.Fail("b.mp4", errors.New("not found"))
+	p.Finish("c.jpg")
+
+	if last.Done != 2 || last.Failed != 1 || last.Total != 3 {
+		t.Errorf("%d done and %d failed of %d", last.Done, last.Failed, last.Total)
+	}
+	if f := last.TotalFraction(); f != 1 {
+		t.Errorf("fetch ended at %.0f%%", f*100)
+	}
+
+	p.Reset(1)
+	p.Fail("d.mp4", errors.New("not found"))
+	if last.Finished() != 1 || last.TotalFraction() != 1 {
+		t.Errorf("%d of %d after a reset", last.Finished(), last.Total)
+	}
+}
@@ -0,0 +1,195 @@
+// Package cdn looks up publications, songs and videos on jw-cdn, the content delivery network of jw.org.
+// Lookups are cached, so they can be repeated offline.
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/jwpub"
+)
+
+// Resolutions of the videos on the CDN. Audio gets the mp3 of songs; videos use the lowest resolution.
+const (
+	Res240 = "240p"
+	Res360 = "360p"
+	Res480 = "480p"
+	Res720 = "720p"
+	Audio  = "audio"
+)
+
+var ErrOffline = errors.New("not going online in offline mode")
+
+// Client looks up media in Language. Responses and song titles are cached in CacheDir.
+type Client struct {
+	HTTP     *retryablehttp.Client
+	Language string
+	CacheDir string
+	Offline  bool // only use cached responses, and fail requests with ErrOffline
+}
+
+// MediaInfo is the answer of GETPUBMEDIALINKS
+type MediaInfo struct {
+	Files map[string]LanguageFiles
+}
+
+type LanguageFiles struct {
+	JWPUB []JWPubItem `json:"JWPUB"`
+	MP4   []MP4       `json:"MP4"`
+	MP3   []MP4       `json:"MP3"`
+}
+
+type JWPubItem struct {
+	File struct {
+		URL      string `json:"url"`
+		Checksum string `json:"checksum"`
+	} `json:"file"`
+	Filesize int `json:"filesize"`
+}
+
+type MP4 struct {
+	Title string `json:"title"`
+	Track int    `json:"track"`
+	File  struct {
+		URL      string `json:"url"`
+		Checksum string `json:"checksum"`
+	} `json:"file"`
+	TrackImage struct {
+		URL string `json:"url"`
+	} `json:"trackImage"`
+	Filesize int `json:"filesize"`
+}
+
+// PubVideo is the answer of media-items
+type PubVideo struct {
+	Media []Media `json:"media"`
+}
+
+type Media struct {
+	Title  string                       `json:"title"`
+	Images map[string]map[string]string `json:"images"` // by shape, then size
+	Files  []Files                      `json:"files"`
+}
+
+// Thumbnail picks a small wide image of the video, if there is one
+func (m Media) Thumbnail() string {
+	for _, shape := range []string{"pnr", "lss", "wss", "sqr"} {
+		for _, size := range []string{"sm", "md", "xs"} {
+			if url := m.Images[shape][size]; url != "" {
+				return url
+			}
+		}
+	}
+	return ""
+}
+
+type Files struct {
+	Progressivedownloadurl string `json:"progressiveDownloadURL"`
+	Checksum               string `json:"checksum"`
+	Filesize               int    `json:"filesize"`
+	Label                  string `json:"label"`
+	Subtitled              bool   `json:"subtitled"`
+}
+
+// Get is a GET request that gives up when ctx is done, even while waiting to retry
+func (c *Client) Get(ctx context.Context, url string, header http.Header) (*http.Response, error) {
+	if c.Offline {
+		return nil, ErrOffline
+	}
+	req, err := retryablehttp.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	return c.HTTP.Do(req.WithContext(ctx))
+}
+
+// JWPubInfo looks up the JWPUB of pub; for w and mwb, the issue published in year and month
+func (c *Client) JWPubInfo(ctx context.Context, pub string, year, month int) (*MediaInfo, error) {
+	var str, name string
+	switch pub {
+	case "w", "mwb":
+		str = fmt.Sprintf("https://b.jw-cdn.org/apis/pub-media/GETPUBMEDIALINKS?issue=%d%02d&output=json&pub=%s&fileformat=JWPUB&alllangs=0&langwritten=%s&txtCMSLang=%s", year, month, pub, c.Language, c.Language)
+		name = fmt.Sprintf("%s %s-%d-%02d", pub, c.Language, year, month)
+	default:
+		str = fmt.Sprintf("https://b.jw-cdn.org/apis/pub-media/GETPUBMEDIALINKS?output=json&pub=%s&fileformat=JWPUB&alllangs=0&langwritten=%s&txtCMSLang=%s", pub, c.Language, c.Language)
+		name = fmt.Sprintf("%s %s", pub, c.Language)
+	}
+	logrus.Debug("JWPubInfo()", str)
+
+	body, err := c.Metadata(ctx, str)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info for %s: %w", name, err)
+	}
+
+	info := new(MediaInfo)
+	err = json.Unmarshal(body, info)
+
+	return info, err
+}
+
+// SongInfo looks up song num in format mp4 or mp3
+func (c *Client) SongInfo(ctx context.Context, num, format string) (*MediaInfo, error) {
+	logrus.Debug("fetching info for song number " + num)
+	body, err := c.Metadata(ctx, fmt.Sprintf("https://b.jw-cdn.org/apis/pub-media/GETPUBMEDIALINKS?output=json&pub=sjjm&fileformat=%s&alllangs=0&track=%s&langwritten=%s&txtCMSLang=%s", format, num, c.Language, c.Language))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info for song #%s: %w", num, err)
+	}
+
+	info := new(MediaInfo)
+	err = json.Unmarshal(body, info)
+
+	logrus.Debugf("fetched #%v: %#v", num, info)
+	return info, err
+}
+
+// VideoInfo looks up a video by its document, or by its publication when it has none
+func (c *Client) VideoInfo(ctx context.Context, v jwpub.Video) (*MediaInfo, error) {
+	logrus.Debugf("fetching info for video: %#v ", v)
+	variable := ""
+	if v.MepsDocumentID.Valid {
+		variable = fmt.Sprintf("&docid=%v", v.MepsDocumentID.Int64)
+	} else {
+		variable = fmt.Sprintf("&pub=%s", v.KeySymbol.String)
+	}
+	url := fmt.Sprintf("https://b.jw-cdn.org/apis/pub-media/GETPUBMEDIALINKS?%s&output=json&fileformat=mp4&alllangs=0&track=%v&langwritten=%s&txtCMSLang=%s", variable, v.Track.Int64, c.Language, c.Language)
+
+	logrus.Debug("VideoInfo() url:", url)
+	body, err := c.Metadata(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info for video %s: %w", v.KeySymbol.String, err)
+	}
+
+	info := new(MediaInfo)
+	err = json.Unmarshal(body, info)
+
+	logrus.Debug("VideoInfo() info:", info)
+	return info, err
+}
+
+// PubVideoInfo looks up a video of an issue of a publication.
+// example: https://b.jw-cdn.org/apis/mediator/v1/media-items/E/pub-jwbcov_201605_4_VIDEO
+func (c *Client) PubVideoInfo(ctx context.Context, v jwpub.Video) (*PubVideo, error) {
+	logrus.Debugf("fetching info for video: %#v ", v)
+	url := fmt.Sprintf("https://b.jw-cdn.org/apis/mediator/v1/media-items/%s/pub-%s_%v_%v_VIDEO", c.Language, v.KeySymbol.String, v.IssueTagNumber/100, v.Track.Int64)
+	logrus.Debug("PubVideoInfo() url:", url)
+
+	body, err := c.Metadata(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get media info for video %s: %w", v.KeySymbol.String, err)
+	}
+
+	info := new(PubVideo)
+	err = json.Unmarshal(body, info)
+
+	logrus.Debug("PubVideoInfo() info:", info)
+	return info, err
+}
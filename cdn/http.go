@@ -0,0 +1,148 @@
+package cdn
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/sirupsen/logrus"
+)
+
+// ResponseHeaderTimeout is how long a request waits for the server to start answering
+const ResponseHeaderTimeout = time.Minute
+
+// Settings are the network settings; the durations are written like 30s or 2m
+type Settings struct {
+	Proxy             string   // http, https or socks5 URL; the environment is used when empty
+	CACertificates    []string // PEM files trusted besides the system certificates
+	UserAgent         string
+	RequestsPerMinute int // to each host; 0 is no limit
+	ConnectTimeout    string
+	Retries           int
+	RetryWaitMin      string
+	RetryWaitMax      string
+}
+
+// NewHTTPClient builds a client for every request from the network settings s
+func NewHTTPClient(s Settings) (*retryablehttp.Client, error) {
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryMax = s.Retries
+
+	var err error
+	if client.RetryWaitMin, err = time.ParseDuration(s.RetryWaitMin); err != nil {
+		return nil, fmt.Errorf("RetryWaitMin: %v", err)
+	}
+	if client.RetryWaitMax, err = time.ParseDuration(s.RetryWaitMax); err != nil {
+		return nil, fmt.Errorf("RetryWaitMax: %v", err)
+	}
+	connectTimeout, err := time.ParseDuration(s.ConnectTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("ConnectTimeout: %v", err)
+	}
+
+	transport, ok := client.HTTPClient.Transport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected transport in the HTTP client")
+	}
+	transport.DialContext = (&net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = connectTimeout
+	transport.ResponseHeaderTimeout = ResponseHeaderTimeout
+
+	if s.Proxy != "" {
+		proxy, err := url.Parse(s.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("Proxy: %v", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	if len(s.CACertificates) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			logrus.Warnf("using only the extra CA certificates: %v", err)
+			pool = x509.NewCertPool()
+		}
+		for _, path := range s.CACertificates {
+			pem, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("CACertificates: %v", err)
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CACertificates: no PEM certificates in %s", path)
+			}
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	client.HTTPClient.Transport = &politeTransport{
+		next:      transport,
+		userAgent: s.UserAgent,
+		interval:  perMinute(s.RequestsPerMinute),
+		nextAt:    make(map[string]time.Time),
+	}
+	return client, nil
+}
+
+func perMinute(n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Minute / time.Duration(n)
+}
+
+// politeTransport sets the user agent, and spaces the requests to each host at least interval apart
+type politeTransport struct {
+	next      http.RoundTripper
+	userAgent string
+	interval  time.Duration
+
+	mu     sync.Mutex
+	nextAt map[string]time.Time // when the next request to a host may go out
+}
+
+func (t *politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.wait(req.Context(), req.URL.Host); err != nil {
+		return nil, err
+	}
+	if t.userAgent != "" {
+		// RoundTrip must not change the request it is given
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (t *politeTransport) wait(ctx context.Context, host string) error {
+	if t.interval == 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	now := time.Now()
+	at := t.nextAt[host]
+	if at.Before(now) {
+		at = now
+	}
+	t.nextAt[host] = at.Add(t.interval)
+	t.mu.Unlock()
+
+	if wait := at.Sub(now); wait > 0 {
+		logrus.Debugf("waiting %s before the next request to %s", wait, host)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package cdn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// metadataDir keeps media info responses in CacheDir
+const metadataDir = "metadata"
+
+// MetadataTimeout limits a single media info lookup, including its retries
+const MetadataTimeout = 2 * time.Minute
+
+const (
+	// metadataFresh is how long a response is used without asking the server whether it changed
+	metadataFresh = time.Hour
+	// metadataMaxAge is how long a response is used when the server can't be reached, or offline
+	metadataMaxAge = 30 * 24 * time.Hour
+)
+
+// Metadata reads the body of a media info request. Responses are cached: a recent one is used
+// as is, an older one is revalidated with its ETag or Last-Modified, and any not too old is used
+// offline or when the server can't be reached.
+func (c *Client) Metadata(ctx context.Context, url string) ([]byte, error) {
+	cached := c.readMetadata(url)
+	if cached.fresh(metadataFresh) {
+		logrus.Debug("using cached ", url)
+		return cached.Body, nil
+	}
+	if c.Offline {
+		if cached.fresh(metadataMaxAge) {
+			return cached.Body, nil
+		}
+		return nil, ErrOffline
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, MetadataTimeout)
+	defer cancel()
+
+	header := make(http.Header)
+	if cached != nil && cached.ETag != "" {
+		header.Set("If-None-Match", cached.ETag)
+	}
+	if cached != nil && cached.LastModified != "" {
+		header.Set("If-Modified-Since", cached.LastModified)
+	}
+
+	resp, err := c.Get(ctx, url, header)
+	if err != nil {
+		if ctx.Err() == nil && cached.fresh(metadataMaxAge) {
+			logrus.Warnf("using the cached response from %s: %v", cached.Fetched.Format("2 Jan 15:04"), err)
+			return cached.Body, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		logrus.Debug("not modified ", url)
+		cached.Fetched = time.Now()
+		if err := c.writeMetadata(cached); err != nil {
+			logrus.Warn(err)
+		}
+		return cached.Body, nil
+	}
+	if resp.StatusCode >= 400 {
+		return nil, errors.New(resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	err = c.writeMetadata(&metadataEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now(),
+		Body:         body,
+	})
+	if err != nil {
+		logrus.Warn(err)
+	}
+	return body, nil
+}
+
+// metadataEntry is a cached response, with what is needed to revalidate it
+type metadataEntry struct {
+	URL          string
+	ETag         string `json:",omitempty"`
+	LastModified string `json:",omitempty"`
+	Fetched      time.Time
+	Body         []byte
+}
+
+func (e *metadataEntry) fresh(maxAge time.Duration) bool {
+	return e != nil && time.Since(e.Fetched) < maxAge
+}
+
+func (c *Client) metadataPath(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.CacheDir, metadataDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// readMetadata returns the cached response for url, or nil when there is none
+func (c *Client) readMetadata(url string) *metadataEntry {
+	data, err := os.ReadFile(c.metadataPath(url))
+	if err != nil {
+		return nil
+	}
+	e := new(metadataEntry)
+	if json.Unmarshal(data, e) != nil || e.URL != url {
+		return nil
+	}
+	return e
+}
+
+// writeMetadata caches e; it is written to a temporary file first, since lookups run in parallel
+func (c *Client) writeMetadata(e *metadataEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	path := c.metadataPath(e.URL)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(filepath.Dir(path), "entry-")
+	if err != nil {
+		return err
+	}
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,115 @@
+package cdn
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"meeting-media/internal/jwtest"
+)
+
+// newTestClient is a client of server, without retries, caching into a temporary folder
+func newTestClient(t *testing.T, server *jwtest.CDN) *Client {
+	t.Helper()
+	client, err := NewHTTPClient(Settings{ConnectTimeout: "5s", RetryWaitMin: "1s", RetryWaitMax: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient.Transport = server.Transport()
+	return &Client{HTTP: client, Language: "E", CacheDir: t.TempDir()}
+}
+
+// age makes the cached response for url look like it was fetched d ago
+func age(t *testing.T, c *Client, url string, d time.Duration) {
+	t.Helper()
+	e := c.readMetadata(url)
+	if e == nil {
+		t.Fatalf("%s isn't cached", url)
+	}
+	e.Fetched = time.Now().Add(-d)
+	if err := c.writeMetadata(e); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMetadataRevalidate(t *testing.T) {
+	server := jwtest.NewCDN("E")
+	defer server.Close()
+	url, _ := server.AddFile("info.json", []byte(`{"version":1}`))
+	c := newTestClient(t, server)
+	ctx := context.Background()
+
+	if body, err := c.Metadata(ctx, url); err != nil || string(body) != `{"version":1}` {
+		t.Fatalf("first lookup %s: %v", body, err)
+	}
+	// a recent response is used without asking
+	if _, err := c.Metadata(ctx, url); err != nil || len(server.Requests()) != 1 {
+		t.Errorf("fresh lookup made %d requests: %v", len(server.Requests()), err)
+	}
+
+	// an older one is revalidated, and fresh again when it didn't change
+	age(t, c, url, 2*metadataFresh)
+	if body, err := c.Metadata(ctx, url); err != nil || string(body) != `{"version":1}` || server.NotModified() != 1 {
+		t.Errorf("revalidated %s with %d times not modified: %v", body, server.NotModified(), err)
+	}
+	if !c.readMetadata(url).fresh(metadataFresh) {
+		t.Error("revalidated response isn't fresh")
+	}
+
+	// or replaced when it did
+	server.AddFile("info.json", []byte(`{"version":2}`))
+	age(t, c, url, 2*metadataFresh)
+	if body, err := c.Metadata(ctx, url); err != nil || string(body) != `{"version":2}` {
+		t.Errorf("changed response %s: %v", body, err)
+	}
+}
+
+func TestMetadataServerDown(t *testing.T) {
+	server := jwtest.NewCDN("E")
+	url, _ := server.AddFile("info.json", []byte(`{"version":1}`))
+	c := newTestClient(t, server)
+	ctx := context.Background()
+
+	if _, err := c.Metadata(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	server.Close()
+
+	// a stale response is better than none
+	age(t, c, url, 2*metadataFresh)
+	if body, err := c.Metadata(ctx, url); err != nil || string(body) != `{"version":1}` {
+		t.Errorf("stale response %s: %v", body, err)
+	}
+
+	// but not once it is too old
+	age(t, c, url, 2*metadataMaxAge)
+	if _, err := c.Metadata(ctx, url); err == nil {
+		t.Error("used a response older than the max age")
+	}
+}
+
+func TestMetadataOffline(t *testing.T) {
+	server := jwtest.NewCDN("E")
+	defer server.Close()
+	url, _ := server.AddFile("info.json", []byte(`{"version":1}`))
+	c := newTestClient(t, server)
+	ctx := context.Background()
+
+	if _, err := c.Metadata(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	c.Offline = true
+
+	age(t, c, url, metadataMaxAge-time.Hour)
+	if body, err := c.Metadata(ctx, url); err != nil || string(body) != `{"version":1}` {
+		t.Errorf("offline response %s: %v", body, err)
+	}
+
+	age(t, c, url, metadataMaxAge+time.Hour)
+	if _, err := c.Metadata(ctx, url); err != ErrOffline {
+		t.Errorf("expired offline response: %v", err)
+	}
+	if n := len(server.Requests()); n != 1 {
+		t.Errorf("%d requests offline", n-1)
+	}
+}
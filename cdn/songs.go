@@ -0,0 +1,105 @@
+package cdn
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// songTitlesFile keeps the titles of songs in CacheDir, by language and number,
+// so they can be shown without looking them up again
+const songTitlesFile = "song-titles.json"
+
+// songTitlesMu guards songTitlesFile; the GUI looks titles up in the background
+var songTitlesMu sync.Mutex
+
+// SongTitle returns the title of song num in c.Language, looking it up in format (mp4 or mp3)
+// when it isn't cached
+func (c *Client) SongTitle(ctx context.Context, num, format string) (string, error) {
+	if title := c.CachedSongTitle(num); title != "" {
+		return title, nil
+	}
+	// the media info may be cached even when the title isn't
+	info, err := c.SongInfo(ctx, num, format)
+	if err != nil {
+		return "", err
+	}
+	files := info.Files[c.Language]
+	for _, songs := range [][]MP4{files.MP4, files.MP3} {
+		for _, song := range songs {
+			if song.Title != "" {
+				return c.RememberSongTitle(num, song.Title), nil
+			}
+		}
+	}
+	return "", errors.New("no title for song #" + num)
+}
+
+func (c *Client) readSongTitles() (titles map[string]map[string]string, err error) {
+	titles = make(map[string]map[string]string)
+	data, err := os.ReadFile(filepath.Join(c.CacheDir, songTitlesFile))
+	if os.IsNotExist(err) {
+		return titles, nil
+	}
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &titles)
+	return
+}
+
+// CachedSongTitle is the title of song num in the cache, or empty when it isn't known
+func (c *Client) CachedSongTitle(num string) string {
+	songTitlesMu.Lock()
+	defer songTitlesMu.Unlock()
+
+	titles, err := c.readSongTitles()
+	if err != nil {
+		logrus.Warn(err)
+		return ""
+	}
+	return titles[c.Language][num]
+}
+
+// RememberSongTitle caches the title of song num and returns it without the number
+// some languages start it with
+func (c *Client) RememberSongTitle(num, title string) string {
+	title = strings.TrimSpace(title)
+	for _, sep := range []string{". ", ": ", " - "} {
+		title = strings.TrimPrefix(title, num+sep)
+	}
+
+	songTitlesMu.Lock()
+	defer songTitlesMu.Unlock()
+
+	titles, err := c.readSongTitles()
+	if err != nil {
+		logrus.Warn(err)
+		return title
+	}
+	if titles[c.Language][num] == title {
+		return title
+	}
+	if titles[c.Language] == nil {
+		titles[c.Language] = make(map[string]string)
+	}
+	titles[c.Language][num] = title
+
+	data, err := json.MarshalIndent(titles, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(c.CacheDir, 0777)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(c.CacheDir, songTitlesFile), data, 0644)
+	}
+	if err != nil {
+		logrus.Warnf("unable to cache the title of song %s: %v", num, err)
+	}
+	return title
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/jwpub"
+	"meeting-media/meeting"
+)
+
+// runCommand handles the command line commands; the GUI is used when there are none.
+// Downloads stop when ctx is done.
+func (c *Config) runCommand(ctx context.Context, args []string) error {
+	switch args[0] {
+	case "fetch":
+		return c.fetchCommand(ctx, args[1:])
+	case "verify":
+		return c.verifyCommand(ctx, args[1:])
+	case "export":
+		return c.exportCommand(args[1:])
+	case "import":
+		return c.importCommand(args[1:])
+	case "daemon":
+		return c.daemonCommand(ctx, args[1:])
+	case "inspect":
+		return c.inspectCommand(ctx, args[1:])
+	case "program":
+		return c.programCommand(ctx, args[1:])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+func (c *Config) fetchCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("fetch", flag.ExitOnError)
+	m := flags.String("meeting", MM, "meeting to fetch ("+MM+" or "+WM+")")
+	date := flags.String("date", time.Now().Format("2006-01-02"), "a day in the week to fetch")
+	songs := flags.String("songs", "", "comma separated song numbers; for "+WM+" the first is the public talk song")
+	flags.Parse(args)
+
+	if *m != MM && *m != WM {
+		return fmt.Errorf("unknown meeting %q", *m)
+	}
+
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return err
+	}
+	c.Date = WeekOf(day)
+
+	c.SongsToGet = strings.Split(*songs, ",")
+	for i, song := range c.SongsToGet {
+		if strings.TrimSpace(song) == "" {
+			c.SongsToGet[i] = ""
+			continue
+		}
+		num, err := meeting.ParseSongNumber(song)
+		if err != nil {
+			return err
+		}
+		c.SongsToGet[i] = num
+
+		title, err := c.fetcher().SongTitle(ctx, num)
+		if err != nil {
+			logrus.Warnf("no title for song %s: %v", num, err)
+		}
+		logrus.Info("fetching " + meeting.SongLabel(num, title))
+	}
+	for len(c.SongsToGet) < 3 {
+		c.SongsToGet = append(c.SongsToGet, "")
+	}
+
+	if err := c.fetchMeetingStuff(ctx, *m); err != nil {
+		return err
+	}
+	if summary := c.Report.String(); summary != "" {
+		fmt.Println(summary)
+	}
+	return nil
+}
+
+func (c *Config) verifyCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	refetch := flags.Bool("refetch", false, "download corrupt or missing files again and fix broken links")
+	flags.Parse(args)
+
+	results, err := c.verify(ctx, *refetch)
+	if err != nil {
+		return err
+	}
+
+	broken := 0
+	for _, r := range results {
+		status := ""
+		if r.Fixed {
+			status = " (fixed)"
+		} else {
+			broken++
+		}
+		fmt.Printf("%-12s %s%s\n", r.Problem, r.Path, status)
+	}
+
+	if broken > 0 {
+		return fmt.Errorf("%d files failed verification", broken)
+	}
+	fmt.Println("all files verified")
+	return nil
+}
+
+func (c *Config) exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	meeting := flags.String("meeting", "", "meeting the bundle is for ("+MM+" or "+WM+"); the one fetched last by default")
+	date := flags.String("date", "", "week the bundle is for; the one fetched last by default. With -meeting, the folder the daemon saved it to is exported if there is one")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: meeting-media export [flags] bundle.zip|bundle.tar")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	// the daemon saves every meeting into a folder of its own
+	if *meeting != "" && *date != "" {
+		day, err := time.Parse("2006-01-02", *date)
+		if err != nil {
+			return err
+		}
+		if folder := c.meetingFolder(*meeting, WeekOf(day)); isDirectory(folder) {
+			job := *c
+			job.SaveLocation = folder
+			c = &job
+		}
+	}
+
+	// the download folder only holds the meeting fetched into it last, besides leftovers of earlier ones
+	f, err := c.readLastFetch()
+	switch {
+	case err == nil:
+		if *meeting != "" && *meeting != f.Meeting {
+			return fmt.Errorf("%s holds %s of the week of %s, not %s", c.SaveLocation, f.Meeting, f.Week, *meeting)
+		}
+		if *date != "" {
+			day, err := time.Parse("2006-01-02", *date)
+			if err != nil {
+				return err
+			}
+			if week := WeekOf(day).Format("2006-01-02"); week != f.Week {
+				return fmt.Errorf("%s holds %s of the week of %s, not of %s", c.SaveLocation, f.Meeting, f.Week, week)
+			}
+		}
+	case os.IsNotExist(err):
+		if *meeting == "" || *date == "" {
+			return fmt.Errorf("no fetch is recorded in %s; give -meeting and -date to export everything in it", c.SaveLocation)
+		}
+		logrus.Warnf("no fetch is recorded in %s; exporting everything in it", c.SaveLocation)
+		f = lastFetch{Meeting: *meeting, Week: *date}
+	default:
+		return err
+	}
+
+	out := flags.Arg(0)
+	if out == "" {
+		out = "meeting-" + f.Meeting + "-" + f.Week + ".zip"
+	}
+
+	if err := c.exportBundle(out, f); err != nil {
+		return err
+	}
+	fmt.Println("exported " + out)
+	return nil
+}
+
+func (c *Config) importCommand(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: meeting-media import bundle.zip|bundle.tar")
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("no bundle given")
+	}
+
+	m, err := c.importBundle(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("imported %d files into %s\n", len(m.Files), c.SaveLocation)
+	return nil
+}
+
+func (c *Config) daemonCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	schedule := flags.String("schedule", "Mon 06:00", "comma separated times to check, like \"Mon 06:00, Thu 18:00\"; a time alone means every day")
+	weeks := flags.Int("weeks", 2, "number of weeks, starting with this one, to keep fetched")
+	once := flags.Bool("once", false, "check once and exit")
+	flags.Parse(args)
+
+	times, err := parseSchedule(*schedule)
+	if err != nil {
+		return err
+	}
+	if *weeks < 1 {
+		return errors.New("-weeks must be at least 1")
+	}
+
+	err = c.runDaemon(ctx, times, *weeks, *once)
+	if errors.Is(err, context.Canceled) {
+		logrus.Info("stopping")
+		return nil
+	}
+	return err
+}
+
+func (c *Config) inspectCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ExitOnError)
+	pub := flags.String("pub", "", "publication, like mwb or w; taken from the file name when a file is given")
+	issue := flags.String("issue", "", "issue to get, like 202609; the one with the week of -date by default")
+	lang := flags.String("lang", c.Language, "language of the issue to get")
+	date := flags.String("date", time.Now().Format("2006-01-02"), "a day in the week to show the selection of the meeting queries for")
+	format := flags.String("format", "table", "output format (table or json)")
+	tables := flags.String("tables", strings.Join(inspectTables, ","), "comma separated tables to list")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: meeting-media inspect [flags] file.jwpub")
+		fmt.Fprintln(flags.Output(), "       meeting-media inspect [flags] -pub mwb [-issue 202609] [-lang E]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return err
+	}
+	week := WeekOf(day)
+
+	var name string
+	var data []byte
+	switch {
+	case flags.NArg() == 1:
+		name = flags.Arg(0)
+		if *pub == "" {
+			*pub = strings.SplitN(filepath.Base(name), "_", 2)[0]
+		}
+		data, err = os.ReadFile(name)
+	case *pub != "":
+		c.Language = *lang
+		month := jwpub.IssueOf(*pub, week)
+		if *issue != "" {
+			month, err = time.Parse("200601", *issue)
+			if err != nil {
+				return fmt.Errorf("%q is not an issue like 202609", *issue)
+			}
+		}
+		name = fmt.Sprintf("%s %s %s", *pub, c.Language, month.Format("200601"))
+		data, err = c.fetcher().JWPub(ctx, *pub, month)
+	default:
+		flags.Usage()
+		return errors.New("no file or publication given")
+	}
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, t := range strings.Split(*tables, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			names = append(names, t)
+		}
+	}
+
+	report, err := c.inspectJWPub(data, name, *pub, week, names)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		return report.writeJSON(os.Stdout)
+	}
+	return report.writeTables(os.Stdout)
+}
+
+func (c *Config) programCommand(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("program", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format("2006-01-02"), "a day in the week to print the midweek program of")
+	start := flags.String("start", "19:00", "time the meeting starts")
+	format := flags.String("format", "table", "output format (table or json)")
+	flags.Parse(args)
+
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	day, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return err
+	}
+	c.Date = WeekOf(day)
+	at, err := time.Parse("15:04", *start)
+	if err != nil {
+		return fmt.Errorf("%q is not a time like 19:00", *start)
+	}
+
+	program, err := c.getProgram(ctx)
+	if err != nil {
+		return err
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(program)
+	}
+	return program.WriteRunSheet(os.Stdout, at)
+}
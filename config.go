@@ -3,96 +3,507 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pelletier/go-toml"
 	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/meeting"
 )
 
-func NewConfig() *Config {
-	c := Config{}
+type Config struct {
+	ConfigVersion        int
+	Profile              string
+	AutoFetchMeetingData bool
+	FetchOtherMedia      bool
+	CreatePlaylist       bool
+	PurgeSaveDir         bool
+	Resolution           string
+	OutputMode           string
+	SaveLocation         string
+	CacheLocation        string
+	Offline              bool
+	PublicationFolder    string
+	Proxy                string   // http, https or socks5 URL; the environment is used when empty
+	CACertificates       []string // PEM files trusted besides the system certificates
+	UserAgent            string
+	RequestsPerMinute    int // to each host; 0 is no limit
+	ConnectTimeout       string
+	Retries              int
+	RetryWaitMin         string
+	RetryWaitMax         string
+	Language             string
+	SongsToGet           []string
+	PubSymbols           []string
+	Exclusions           []meeting.Exclusion
+	PreFetchHooks        []string       // shell commands or URLs to post to before a fetch
+	PostFetchHooks       []string       // and after it
+	Report               meeting.Report // what the fetches so far left out or could not find
+	Progress             *cache.Progress
+	HttpClient           *retryablehttp.Client
+	Date                 time.Time
+	DebugMode            *bool
+
+	path       string                 // where the config was loaded from
+	fileValues map[string]interface{} // file values of settings overridden by the environment or command line
+	baseValues map[string]interface{} // top level values of the settings the active profile replaces
+	profiles   *toml.Tree             // settings of every profile, by name
+	loadErrors configErrors           // problems reading the config file
+	unreadable bool                   // the config file couldn't be read, so saving must not replace it
+}
+
+// NewConfig loads the config from path, or from the default location when path is empty.
+// A profile other than "" replaces the one selected in the file; it has to exist.
+func NewConfig(path, profile string) (*Config, error) {
+	c := Config{path: path, Profile: profile}
+	if c.path == "" {
+		c.path = defaultConfigPath()
+	}
 	c.readConfigFromFile()
-	c.HttpClient = newHttpClient()
+	if profile != "" && c.profile(profile) == nil {
+		return nil, c.unknownProfile(profile)
+	}
+	if err := c.applyNetworkSettings(); err != nil {
+		logrus.Warnf("using the default network settings: %v", err)
+		defaults := Config{}
+		defaults.LoadDefaults()
+		c.HttpClient, _ = cdn.NewHTTPClient(defaults.networkSettings())
+	}
 
-	if err := createDirIfNotExist(c.SaveLocation); err != nil {
-		logrus.Warn(err)
+	if validateLocation(c.SaveLocation) == nil {
+		if err := createDirIfNotExist(c.SaveLocation); err != nil {
+			logrus.Warn(err)
+		}
 	}
 
-	return &c
+	return &c, nil
+}
+
+// defaultConfigPath is in $XDG_CONFIG_HOME, which is ~/.config when it isn't set
+func defaultConfigPath() string {
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, APP_NAME, "config.toml")
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".config", APP_NAME, "config.toml")
 }
 
-func newHttpClient() *retryablehttp.Client {
-	client := retryablehttp.NewClient()
-	client.RetryWaitMin = 5 * time.Second
-	client.RetryWaitMax = 60 * time.Second
-	client.RetryMax = 20
-	client.Logger = nil
-	return client
+// defaultCacheLocation is in $XDG_CACHE_HOME, which is ~/.cache when it isn't set
+func defaultCacheLocation() string {
+	if xdg := os.Getenv("XDG_CACHE_HOME"); xdg != "" {
+		return filepath.Join(xdg, APP_NAME)
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".cache", APP_NAME)
+}
+
+// legacyConfigPath is where settings were kept before; they are moved to the default path from there
+func legacyConfigPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, CONFIG_FILE)
 }
 
 func (c *Config) LoadDefaults() {
-	homeDir := os.Getenv("HOME")
+	homeDir, _ := os.UserHomeDir()
 
 	c.AutoFetchMeetingData = true
 	c.FetchOtherMedia = true
 	c.CreatePlaylist = true
+	c.PurgeSaveDir = false
 	c.SaveLocation = filepath.Join(homeDir, "Downloads/meetings")
 	c.Resolution = RES720
+	c.OutputMode = SYMLINK
 	c.Language = "E"
+	c.Offline = false
+	c.PublicationFolder = ""
+	c.Proxy = ""
+	c.CACertificates = nil
+	c.UserAgent = APP_NAME
+	c.RequestsPerMinute = 120
+	c.ConnectTimeout = "30s"
+	c.Retries = 20
+	c.RetryWaitMin = "5s"
+	c.RetryWaitMax = "60s"
 	c.PubSymbols = []string{"th", "bt"}
-	c.CacheLocation = filepath.Join(homeDir, "Downloads/meetings_cache")
+	c.CacheLocation = defaultCacheLocation()
+	c.Exclusions = []meeting.Exclusion{
+		// illustrations in 'th' that are not needed for the meeting
+		{PubSymbol: "th", Filename: "1102018440_univ_cnt_*.jpg"},
+	}
+	c.PreFetchHooks = nil
+	c.PostFetchHooks = nil
+}
+
+// resetSettings puts the settings back to their defaults and saves them. With a profile
+// selected, only its own settings are reset; the shared ones are kept for the other profiles.
+func (c *Config) resetSettings() {
+	if c.profile(c.Profile) == nil {
+		logrus.Info("resetting settings to their defaults")
+		c.LoadDefaults()
+	} else if err := c.resetProfile(); err != nil {
+		logrus.Warn(err)
+		return
+	}
+	c.loadErrors = nil
+	c.writeConfigToFile()
+	if err := c.applyNetworkSettings(); err != nil {
+		logrus.Warn(err)
+	}
+}
+
+// resetProfile puts the settings of the selected profile back to their defaults
+func (c *Config) resetProfile() error {
+	logrus.Infof("resetting the settings of profile %s to their defaults", c.Profile)
+	var defaults Config
+	defaults.LoadDefaults()
+	defaultTree, err := defaults.settingsTree()
+	if err != nil {
+		return err
+	}
+	tree, err := c.settingsTree()
+	if err != nil {
+		return err
+	}
+	for _, key := range profileKeys {
+		tree.Set(key, defaultTree.Get(key))
+	}
+	return c.applyTree(tree)
 }
 
 func (c *Config) readConfigFromFile() {
-	homeDir := os.Getenv("HOME")
 	c.LoadDefaults()
 
-	data, err := os.ReadFile(filepath.Join(homeDir, CONFIG_FILE))
+	moved := false
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) && c.path != legacyConfigPath() {
+		if data, err = os.ReadFile(legacyConfigPath()); err == nil {
+			logrus.Infof("moving settings from %s to %s", legacyConfigPath(), c.path)
+			moved = true
+		}
+	}
+	if err != nil {
+		c.ConfigVersion = len(configMigrations)
+		c.writeConfigToFile()
+		c.applyEnvToDefaults()
+		return
+	}
+
+	tree, err := toml.LoadBytes(data)
 	if err != nil {
+		c.loadErrors = configErrors{{c.path, err}}
+		c.unreadable = true
+		logrus.Warn(c.loadErrors)
+		c.applyEnvToDefaults()
+		return
+	}
+
+	migrated := migrateConfig(tree)
+	c.applyProfile(tree)
+	c.applyEnvOverrides(tree)
+
+	if err = c.applyTree(tree); err != nil {
+		c.loadErrors = configErrors{{c.path, err}}
+		c.unreadable = true
+		logrus.Warn(c.loadErrors)
+		c.applyEnvToDefaults()
+		return
+	}
+
+	if errs := c.validate(); len(errs) > 0 {
+		logrus.Warn(errs)
+	}
+
+	if migrated || moved {
 		c.writeConfigToFile()
 	}
+}
+
+// applyTree sets the settings in tree, or none of them when part of it can't be read
+func (c *Config) applyTree(tree *toml.Tree) error {
+	// settingsTree writes no exclusions as [], which can't be unmarshalled into rules
+	noExclusions := false
+	if rules, ok := tree.Get("Exclusions").([]interface{}); ok && len(rules) == 0 {
+		tree.Delete("Exclusions")
+		noExclusions = true
+	}
 
-	err = toml.Unmarshal(data, c)
+	loaded := *c
+	if err := tree.Unmarshal(&loaded); err != nil {
+		return err
+	}
+	if noExclusions {
+		loaded.Exclusions = nil
+	}
+	*c = loaded
+	return nil
+}
+
+// applyEnvToDefaults applies the environment overrides when there is no config file to apply them to,
+// on the first run or when the file is broken. Like any override, they are not saved.
+func (c *Config) applyEnvToDefaults() {
+	tree, err := c.settingsTree()
 	if err != nil {
 		logrus.Warn(err)
+		return
+	}
+	c.applyEnvOverrides(tree)
+	if err := c.applyTree(tree); err != nil {
+		logrus.Warn(err)
 	}
 }
 
 func (c *Config) writeConfigToFile() {
-	homeDir := os.Getenv("HOME")
-
 	logrus.Info("Saving settings")
 
+	if c.unreadable {
+		// it may hold settings and profiles that only a typo keeps from being read
+		backup := c.path + ".broken"
+		if err := os.Rename(c.path, backup); err != nil && !os.IsNotExist(err) {
+			logrus.Warnf("not saving over %s, which couldn't be read: %v", c.path, err)
+			return
+		}
+		logrus.Warnf("%s couldn't be read; it was kept as %s", c.path, backup)
+		c.unreadable = false
+	}
+
+	tree, err := c.settingsTree()
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	// the active profile owns its settings; the top level keeps what the file had.
+	// Profiles are only created by addProfile, so a profile that doesn't exist is not saved.
+	if profile := c.profile(c.Profile); profile != nil {
+		for _, key := range profileKeys {
+			setOrDelete(profile, key, tree.Get(key))
+			setOrDelete(tree, key, c.baseValues[key])
+		}
+	}
+	if c.profiles != nil {
+		tree.Set("Profiles", c.profiles)
+	}
+
+	if err := writeTree(c.path, tree); err != nil {
+		logrus.Warn(err)
+	}
+}
+
+// settingsTree returns the settings as they should be saved
+func (c *Config) settingsTree() (*toml.Tree, error) {
 	config := struct {
+		ConfigVersion        int
+		Profile              string
 		AutoFetchMeetingData bool
 		FetchOtherMedia      bool
 		CreatePlaylist       bool
 		PurgeSaveDir         bool
 		Resolution           string
+		OutputMode           string
 		SaveLocation         string
 		Language             string
 		CacheLocation        string
+		Offline              bool
+		PublicationFolder    string
+		Proxy                string
+		CACertificates       []string
+		UserAgent            string
+		RequestsPerMinute    int
+		ConnectTimeout       string
+		Retries              int
+		RetryWaitMin         string
+		RetryWaitMax         string
 		PubSymbols           []string
+		Exclusions           []meeting.Exclusion
+		PreFetchHooks        []string
+		PostFetchHooks       []string
 	}{
+		ConfigVersion:        c.ConfigVersion,
+		Profile:              c.Profile,
 		AutoFetchMeetingData: c.AutoFetchMeetingData,
 		FetchOtherMedia:      c.FetchOtherMedia,
 		CreatePlaylist:       c.CreatePlaylist,
 		PurgeSaveDir:         c.PurgeSaveDir,
 		Resolution:           c.Resolution,
+		OutputMode:           c.OutputMode,
 		SaveLocation:         c.SaveLocation,
 		Language:             c.Language,
 		PubSymbols:           c.PubSymbols,
 		CacheLocation:        c.CacheLocation,
+		Offline:              c.Offline,
+		PublicationFolder:    c.PublicationFolder,
+		Proxy:                c.Proxy,
+		CACertificates:       c.CACertificates,
+		UserAgent:            c.UserAgent,
+		RequestsPerMinute:    c.RequestsPerMinute,
+		ConnectTimeout:       c.ConnectTimeout,
+		Retries:              c.Retries,
+		RetryWaitMin:         c.RetryWaitMin,
+		RetryWaitMax:         c.RetryWaitMax,
+		Exclusions:           c.Exclusions,
+		PreFetchHooks:        c.PreFetchHooks,
+		PostFetchHooks:       c.PostFetchHooks,
 	}
 
 	configToml, err := toml.Marshal(config)
 	if err != nil {
-		logrus.Warn(err)
+		return nil, err
 	}
 
-	err = os.WriteFile(filepath.Join(homeDir, CONFIG_FILE), configToml, 0644)
+	tree, err := toml.LoadBytes(configToml)
 	if err != nil {
-		logrus.Warn(err)
+		return nil, err
+	}
+
+	// an empty list is left out, which would bring back the default rules on the next load
+	if len(c.Exclusions) == 0 {
+		tree.Set("Exclusions", []interface{}{})
+	}
+
+	// settings from the environment or the command line are not saved; keep what the file had
+	for key, value := range c.fileValues {
+		setOrDelete(tree, key, value)
+	}
+
+	return tree, nil
+}
+
+func writeTree(path string, tree *toml.Tree) error {
+	configToml, err := tree.Marshal()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, configToml, 0644)
+}
+
+func setOrDelete(tree *toml.Tree, key string, value interface{}) {
+	if value == nil {
+		tree.Delete(key)
+	} else {
+		tree.Set(key, value)
+	}
+}
+
+// configMigrations upgrade a config file one schema version at a time;
+// configMigrations[n] turns a version n file into version n+1
+var configMigrations = []func(*toml.Tree){
+	// version 0 files predate ConfigVersion; paths typed into the settings as ~/... were never expanded
+	func(tree *toml.Tree) {
+		homeDir, _ := os.UserHomeDir()
+		for _, key := range []string{"SaveLocation", "CacheLocation"} {
+			if path, ok := tree.Get(key).(string); ok && strings.HasPrefix(path, "~/") {
+				tree.Set(key, filepath.Join(homeDir, path[2:]))
+			}
+		}
+	},
+}
+
+// migrateConfig upgrades tree to the current schema version and reports whether anything changed
+func migrateConfig(tree *toml.Tree) bool {
+	version, _ := tree.GetDefault("ConfigVersion", int64(0)).(int64)
+	if int(version) > len(configMigrations) {
+		logrus.Warnf("config version %d is newer than this version of %s supports", version, APP_NAME)
+		return false
+	}
+
+	migrated := false
+	for v := int(version); v < len(configMigrations); v++ {
+		logrus.Infof("migrating settings from version %d", v)
+		configMigrations[v](tree)
+		migrated = true
+	}
+	tree.Set("ConfigVersion", int64(len(configMigrations)))
+
+	return migrated
+}
+
+// applyEnvOverrides replaces settings in tree with MEETING_MEDIA_* environment variables,
+// eg. MEETING_MEDIA_SAVE_LOCATION for SaveLocation. Lists are separated by commas.
+func (c *Config) applyEnvOverrides(tree *toml.Tree) {
+	config := reflect.ValueOf(c).Elem()
+	for _, key := range envKeys {
+		env := envName(key)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+
+		c.setFileValue(key, tree.Get(key))
+
+		switch config.FieldByName(key).Kind() {
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				logrus.Warnf("ignoring %s: %v", env, err)
+				delete(c.fileValues, key)
+				continue
+			}
+			tree.Set(key, b)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				logrus.Warnf("ignoring %s: %v", env, err)
+				delete(c.fileValues, key)
+				continue
+			}
+			tree.Set(key, int64(n))
+		case reflect.Slice:
+			var list []string
+			for _, v := range strings.Split(value, ",") {
+				list = append(list, strings.TrimSpace(v))
+			}
+			tree.Set(key, list)
+		default:
+			tree.Set(key, value)
+		}
+		logrus.Infof("%s is set by %s", key, env)
+	}
+}
+
+// envKeys are the settings that can be overridden from the environment
+var envKeys = []string{
+	"AutoFetchMeetingData",
+	"FetchOtherMedia",
+	"CreatePlaylist",
+	"PurgeSaveDir",
+	"Resolution",
+	"OutputMode",
+	"SaveLocation",
+	"CacheLocation",
+	"Offline",
+	"PublicationFolder",
+	"Proxy",
+	"CACertificates",
+	"UserAgent",
+	"RequestsPerMinute",
+	"ConnectTimeout",
+	"Retries",
+	"RetryWaitMin",
+	"RetryWaitMax",
+	"Language",
+	"PubSymbols",
+}
+
+// envName turns a setting like SaveLocation into MEETING_MEDIA_SAVE_LOCATION
+func envName(key string) string {
+	name := "MEETING_MEDIA"
+	for i, r := range key {
+		if i == 0 || unicode.IsUpper(r) {
+			name += "_"
+		}
+		name += string(unicode.ToUpper(r))
 	}
+	return name
 }
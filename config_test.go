@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setenv sets an environment variable for the rest of the test
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestEnvOverridesWithoutConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	language := filepath.Join(dir, "language.toml")
+	broken := filepath.Join(dir, "broken.toml")
+	if err := os.WriteFile(broken, []byte("Language = "), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wrongType := filepath.Join(dir, "wrong-type.toml")
+	if err := os.WriteFile(wrongType, []byte("Retries = \"many\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "HOME", dir) // no legacy config to move
+	setenv(t, "MEETING_MEDIA_LANGUAGE", "S")
+
+	for _, path := range []string{language, broken, wrongType} {
+		c := &Config{path: path}
+		c.readConfigFromFile()
+		if c.Language != "S" {
+			t.Errorf("%s: language %q, want the one from the environment", filepath.Base(path), c.Language)
+		}
+	}
+
+	// the first run writes the defaults, not the overrides
+	data, err := os.ReadFile(language)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `Language = "E"`) {
+		t.Errorf("new config file:\n%s", data)
+	}
+}
+
+func TestDefaultLocations(t *testing.T) {
+	setenv(t, "XDG_CONFIG_HOME", "")
+	os.Unsetenv("XDG_CONFIG_HOME")
+	setenv(t, "XDG_CACHE_HOME", "")
+	os.Unsetenv("XDG_CACHE_HOME")
+	homeDir, _ := os.UserHomeDir()
+	if path, want := defaultConfigPath(), filepath.Join(homeDir, ".config", APP_NAME, "config.toml"); path != want {
+		t.Errorf("config path %s, want %s", path, want)
+	}
+	if path, want := defaultCacheLocation(), filepath.Join(homeDir, ".cache", APP_NAME); path != want {
+		t.Errorf("cache location %s, want %s", path, want)
+	}
+}
+
+func TestUnknownProfile(t *testing.T) {
+	dir := t.TempDir()
+	setenv(t, "HOME", dir)
+	path := filepath.Join(dir, "config.toml")
+	config := "ConfigVersion = 1\nLanguage = \"E\"\n\n[Profiles.hall]\nLanguage = \"S\"\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewConfig(path, "hal"); err == nil || !strings.Contains(err.Error(), "the profiles are hall") {
+		t.Errorf("unknown profile: %v", err)
+	}
+
+	c, err := NewConfig(path, "hall")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Language != "S" {
+		t.Errorf("language %q in profile hall", c.Language)
+	}
+
+	// saving with a profile that doesn't exist doesn't make one
+	c = &Config{path: path, Profile: "hal"}
+	c.readConfigFromFile()
+	c.writeConfigToFile()
+	c = &Config{path: path}
+	c.readConfigFromFile()
+	if names := c.profileNames(); len(names) != 1 {
+		t.Errorf("profiles %v after saving with an unknown one", names)
+	}
+}
+
+func TestSaveKeepsUnreadableConfig(t *testing.T) {
+	dir := t.TempDir()
+	setenv(t, "HOME", dir)
+	path := filepath.Join(dir, "config.toml")
+	config := "ConfigVersion = 1\nRetries = \"many\"\n\n[Profiles.hall]\nLanguage = \"S\"\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{path: path}
+	c.readConfigFromFile()
+	c.loadErrors = nil // as the settings form does
+	c.writeConfigToFile()
+
+	data, err := os.ReadFile(path + ".broken")
+	if err != nil || string(data) != config {
+		t.Errorf("backup %q: %v", data, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("settings weren't saved: %v", err)
+	}
+}
+
+func TestResetProfile(t *testing.T) {
+	dir := t.TempDir()
+	setenv(t, "HOME", dir)
+	path := filepath.Join(dir, "config.toml")
+	config := "ConfigVersion = 1\nProfile = \"hall\"\nLanguage = \"E\"\nRetries = 3\nProxy = \"http://proxy:3128\"\n\n[Profiles.hall]\nLanguage = \"S\"\nPurgeSaveDir = true\n"
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.resetSettings()
+	if c.Language != "E" || c.PurgeSaveDir || c.Retries != 3 || c.Proxy != "http://proxy:3128" {
+		t.Errorf("after the reset: language %q, purge %v, retries %d, proxy %q", c.Language, c.PurgeSaveDir, c.Retries, c.Proxy)
+	}
+
+	// the shared settings are kept in the file too
+	c, err = NewConfig(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Profile != "hall" || c.Language != "E" || c.Retries != 3 || c.Proxy != "http://proxy:3128" {
+		t.Errorf("saved: profile %q, language %q, retries %d, proxy %q", c.Profile, c.Language, c.Retries, c.Proxy)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/jwpub"
+)
+
+// statusFile is written to CacheLocation by the daemon, for the GUI to show
+const statusFile = "daemon-status.json"
+
+const (
+	retryMin = time.Minute
+	retryMax = time.Hour
+)
+
+// daemonStatus is what the daemon knows about the upcoming meetings
+type daemonStatus struct {
+	Checked  time.Time
+	NextRun  time.Time
+	Meetings []meetingStatus
+}
+
+type meetingStatus struct {
+	Meeting   string
+	Week      string
+	Folder    string
+	Files     []string  `json:",omitempty"`
+	Fetched   time.Time `json:",omitempty"`
+	Error     string    `json:",omitempty"`
+	Attempts  int       `json:",omitempty"`
+	NextRetry time.Time `json:",omitempty"`
+	Hooks     []string  `json:",omitempty"` // hooks of the last fetch that failed
+}
+
+// present reports whether the meeting was fetched and all its files are still there
+func (s meetingStatus) present() bool {
+	if s.Fetched.IsZero() {
+		return false
+	}
+	for _, name := range s.Files {
+		// Stat follows links, so a link into a cleared cache counts as missing
+		if _, err := os.Stat(filepath.Join(s.Folder, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (s meetingStatus) String() string {
+	if len(s.Hooks) > 0 {
+		return s.fetchString() + "; failed hooks: " + strings.Join(s.Hooks, "; ")
+	}
+	return s.fetchString()
+}
+
+func (s meetingStatus) fetchString() string {
+	switch {
+	case s.Error != "":
+		return fmt.Sprintf("%s %s failed: %s (retrying at %s)", s.Meeting, s.Week, s.Error, s.NextRetry.Format("Mon 15:04"))
+	case !s.Fetched.IsZero():
+		return fmt.Sprintf("%s %s fetched %s", s.Meeting, s.Week, s.Fetched.Format("Mon 2 Jan 15:04"))
+	}
+	return fmt.Sprintf("%s %s not fetched yet", s.Meeting, s.Week)
+}
+
+// daemonStatusText describes the last check of a daemon using the same cache, or is empty without one
+func (c *Config) daemonStatusText() string {
+	status, err := c.readDaemonStatus()
+	if err != nil {
+		return ""
+	}
+
+	lines := []string{fmt.Sprintf("Scheduled fetching: checked %s, next check %s",
+		status.Checked.Format("Mon 2 Jan 15:04"), status.NextRun.Format("Mon 2 Jan 15:04"))}
+	for _, s := range status.Meetings {
+		lines = append(lines, s.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *Config) readDaemonStatus() (status daemonStatus, err error) {
+	data, err := os.ReadFile(filepath.Join(c.CacheLocation, statusFile))
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(data, &status)
+	return
+}
+
+func (c *Config) writeDaemonStatus(status daemonStatus) error {
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := createDirIfNotExist(c.CacheLocation); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(c.CacheLocation, statusFile), data, 0644)
+}
+
+// scheduleTime is a time of day, on one weekday or on every day
+type scheduleTime struct {
+	weekday *time.Weekday
+	hour    int
+	minute  int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseSchedule reads times like "Mon 06:00, Thu 18:00"; a time without a day means every day
+func parseSchedule(text string) (schedule []scheduleTime, err error) {
+	for _, part := range strings.Split(text, ",") {
+		fields := strings.Fields(part)
+		var st scheduleTime
+		switch len(fields) {
+		case 1:
+		case 2:
+			day, ok := weekdays[strings.ToLower(fields[0])]
+			if !ok && len(fields[0]) > 3 {
+				day, ok = weekdays[strings.ToLower(fields[0][:3])]
+			}
+			if !ok {
+				return nil, fmt.Errorf("unknown day %q", fields[0])
+			}
+			st.weekday = &day
+			fields = fields[1:]
+		default:
+			return nil, fmt.Errorf("%q is not a time like Mon 06:00", strings.TrimSpace(part))
+		}
+
+		t, err := time.Parse("15:04", fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a time like 06:00", fields[0])
+		}
+		st.hour, st.minute = t.Hour(), t.Minute()
+		schedule = append(schedule, st)
+	}
+	return schedule, nil
+}
+
+// nextRun is the first time in schedule after now
+func nextRun(schedule []scheduleTime, now time.Time) (next time.Time) {
+	for _, st := range schedule {
+		for d := 0; d <= 7; d++ {
+			day := now.AddDate(0, 0, d)
+			t := time.Date(day.Year(), day.Month(), day.Day(), st.hour, st.minute, 0, 0, now.Location())
+			if !t.After(now) || (st.weekday != nil && t.Weekday() != *st.weekday) {
+				continue
+			}
+			if next.IsZero() || t.Before(next) {
+				next = t
+			}
+			break
+		}
+	}
+	return
+}
+
+// meetingFolder is where the daemon saves meeting m of the week of week
+func (c *Config) meetingFolder(m string, week time.Time) string {
+	return filepath.Join(c.SaveLocation, week.Format("2006-01-02")+"-"+m)
+}
+
+// isMeetingFolder tells the folders of the daemon apart, so fetches into SaveLocation don't purge them
+func isMeetingFolder(name string) bool {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return false
+	}
+	_, err := time.Parse("2006-01-02", name[:i])
+	m := name[i+1:]
+	return err == nil && (m == MM || m == WM)
+}
+
+// runDaemon checks the meetings of the next weeks right away and then at every time in schedule,
+// fetching whatever is missing into a folder per meeting. A failed fetch is retried with backoff.
+func (c *Config) runDaemon(ctx context.Context, schedule []scheduleTime, weeks int, once bool) error {
+	status, err := c.readDaemonStatus()
+	if err != nil && !os.IsNotExist(err) {
+		logrus.Warnf("starting with a new status: %v", err)
+	}
+
+	for {
+		retry := c.checkUpcoming(ctx, &status, weeks, time.Now())
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		status.NextRun = nextRun(schedule, time.Now())
+		if !retry.IsZero() && retry.Before(status.NextRun) {
+			status.NextRun = retry
+		}
+		if err := c.writeDaemonStatus(status); err != nil {
+			logrus.Warn(err)
+		}
+
+		if once {
+			for _, s := range status.Meetings {
+				if s.Error != "" {
+					return errors.New("some meetings could not be fetched")
+				}
+			}
+			return nil
+		}
+
+		logrus.Infof("next check at %s", status.NextRun.Format("Mon 2 Jan 15:04"))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Until(status.NextRun)):
+		}
+	}
+}
+
+// checkUpcoming fetches the meetings of the week of now and the next weeks that are missing,
+// and returns when the first failed one should be retried
+func (c *Config) checkUpcoming(ctx context.Context, status *daemonStatus, weeks int, now time.Time) (retry time.Time) {
+	status.Checked = now
+
+	previous := make(map[string]meetingStatus)
+	for _, s := range status.Meetings {
+		previous[s.Meeting+s.Week] = s
+	}
+	status.Meetings = nil
+
+	for w := 0; w < weeks; w++ {
+		// the Monday at midnight UTC, like the dates in publications, whatever the time and zone of now
+		week := WeekOf(jwpub.Day(now.AddDate(0, 0, 7*w)))
+		for _, m := range []string{MM, WM} {
+			s, ok := previous[m+week.Format("2006-01-02")]
+			if !ok {
+				s = meetingStatus{Meeting: m, Week: week.Format("2006-01-02"), Folder: c.meetingFolder(m, week)}
+			}
+
+			switch {
+			case s.present():
+				logrus.Debugf("%s is present", s)
+			case s.Error != "" && now.Before(s.NextRetry):
+				logrus.Infof("waiting to retry %s %s", m, s.Week)
+			case ctx.Err() != nil:
+			default:
+				c.fetchInto(ctx, &s, week)
+			}
+
+			if s.Error != "" && (retry.IsZero() || s.NextRetry.Before(retry)) {
+				retry = s.NextRetry
+			}
+			status.Meetings = append(status.Meetings, s)
+		}
+	}
+	return
+}
+
+// fetchInto fetches meeting s.Meeting into s.Folder, and records the result in s
+func (c *Config) fetchInto(ctx context.Context, s *meetingStatus, week time.Time) {
+	logrus.Infof("fetching %s for the week of %s into %s", s.Meeting, s.Week, s.Folder)
+
+	// a copy, so a fetch can't leave anything behind in the settings
+	job := *c
+	job.SaveLocation = s.Folder
+	job.Date = week
+	job.AutoFetchMeetingData = true
+	job.SongsToGet = []string{"", "", ""}
+
+	items, err := job.fetchAndSave(ctx, s.Meeting)
+	if ctx.Err() != nil {
+		return
+	}
+	s.Hooks = job.Report.Hooks
+
+	if err != nil {
+		s.Attempts++
+		wait := retryMax
+		if s.Attempts <= 10 {
+			wait = retryMin << uint(s.Attempts-1)
+		}
+		if wait > retryMax {
+			wait = retryMax
+		}
+		s.Error = err.Error()
+		s.NextRetry = time.Now().Add(wait)
+		logrus.Warnf("%s; retrying in %s", s, wait)
+		return
+	}
+
+	s.Files = nil
+	for _, it := range items {
+		if it.Include {
+			s.Files = append(s.Files, it.Name)
+		}
+	}
+	s.Fetched = time.Now()
+	s.Error, s.Attempts, s.NextRetry = "", 0, time.Time{}
+	logrus.Info(s)
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckUpcoming(t *testing.T) {
+	c := newTestConfig(t, newTestCDN(t))
+
+	// Wednesday afternoon of the sample week, east of UTC
+	now := time.Date(2026, 9, 9, 14, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+	var status daemonStatus
+	if retry := c.checkUpcoming(context.Background(), &status, 1, now); !retry.IsZero() {
+		t.Errorf("retry at %s", retry)
+	}
+
+	if len(status.Meetings) != 2 {
+		t.Fatalf("meetings %v", status.Meetings)
+	}
+	for _, s := range status.Meetings {
+		if s.Week != "2026-09-07" || s.Error != "" || s.Fetched.IsZero() || len(s.Files) == 0 {
+			t.Errorf("%s: %+v", s, s)
+		}
+		if _, err := os.Stat(filepath.Join(c.SaveLocation, "2026-09-07-"+s.Meeting, s.Files[0])); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestMeetingFoldersKept(t *testing.T) {
+	c := newTestConfig(t, newTestCDN(t))
+	now := time.Date(2026, 9, 9, 14, 30, 0, 0, time.UTC)
+	var status daemonStatus
+	c.checkUpcoming(context.Background(), &status, 1, now)
+
+	// the folders of the daemon can be exported by their meeting and week
+	out := filepath.Join(t.TempDir(), "bundle.zip")
+	if err := c.exportCommand([]string{"-meeting", MM, "-date", "2026-09-09", out}); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.File) != len(status.Meetings[0].Files)+2 { // with the manifest and the playlist
+		t.Errorf("bundle of %d files for %v", len(r.File), status.Meetings[0].Files)
+	}
+	r.Close()
+
+	// and aren't purged by other fetches
+	c.PurgeSaveDir = true
+	if err := c.fetchMeetingStuff(context.Background(), WM); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []string{MM, WM} {
+		if !isDirectory(filepath.Join(c.SaveLocation, "2026-09-07-"+m)) {
+			t.Errorf("folder of %s purged", m)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+
+	"meeting-media/jwpub"
+)
+
+// weekPicker chooses the week of a meeting, one week at a time or from a calendar,
+// and tells which issue the meeting of that week is in
+type weekPicker struct {
+	c    *Config
+	pub  string
+	week time.Time
+
+	label *widget.Label
+	issue *widget.Label
+}
+
+// newWeekPicker starts at the current week; pub is the publication the meetings of m are in
+func (c *Config) newWeekPicker(m string) *weekPicker {
+	p := &weekPicker{
+		c:     c,
+		pub:   "mwb",
+		label: widget.NewLabel(""),
+		issue: widget.NewLabel(""),
+	}
+	if m == WM {
+		p.pub = "w"
+	}
+	p.label.Alignment = fyne.TextAlignCenter
+	p.set(time.Now())
+	return p
+}
+
+// set picks the week of day, as the Monday at midnight UTC like the dates in publications
+func (p *weekPicker) set(day time.Time) {
+	p.week = WeekOf(jwpub.Day(day))
+	p.label.SetText("Week of " + p.week.Format("Mon 2 Jan 2006"))
+
+	text := issueName(p.pub, jwpub.IssueOf(p.pub, p.week))
+	if p.c.issueCached(p.pub, p.week) {
+		text += " (cached)"
+	}
+	p.issue.SetText(text)
+}
+
+// widget returns the picker; the calendar pops up over the window it is in
+func (p *weekPicker) widget() fyne.CanvasObject {
+	prev := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+		p.set(p.week.AddDate(0, 0, -7))
+	})
+	next := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+		p.set(p.week.AddDate(0, 0, 7))
+	})
+
+	var calendarButton *widget.Button
+	calendarButton = widget.NewButton("Calendar", func() {
+		canvas := fyne.CurrentApp().Driver().CanvasForObject(calendarButton)
+		if canvas == nil {
+			return
+		}
+		var popUp *widget.PopUp
+		popUp = widget.NewModalPopUp(p.calendar(p.week, func(day time.Time) {
+			p.set(day)
+			popUp.Hide()
+		}, func() {
+			popUp.Hide()
+		}), canvas)
+		popUp.Show()
+	})
+
+	return container.NewVBox(
+		container.NewBorder(nil, nil, prev, container.NewHBox(calendarButton, next), p.label),
+		p.issue,
+	)
+}
+
+// calendar shows the month of shown with a button per day. The chosen week stands out
+// and the days of weeks whose issue is cached are highlighted.
+func (p *weekPicker) calendar(shown time.Time, pick func(time.Time), cancel func()) fyne.CanvasObject {
+	month := time.Date(shown.Year(), shown.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	box := container.NewVBox()
+	var draw func()
+	draw = func() {
+		title := widget.NewLabel(month.Format("January 2006"))
+		title.Alignment = fyne.TextAlignCenter
+		prev := widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() {
+			month = month.AddDate(0, -1, 0)
+			draw()
+		})
+		next := widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() {
+			month = month.AddDate(0, 1, 0)
+			draw()
+		})
+
+		days := container.NewGridWithColumns(7)
+		for _, name := range []string{"Mo", "Tu", "We", "Th", "Fr", "Sa", "Su"} {
+			l := widget.NewLabel(name)
+			l.Alignment = fyne.TextAlignCenter
+			days.Add(l)
+		}
+
+		// weeks start on Monday, like WeekOf
+		for day := WeekOf(month); day.Before(month.AddDate(0, 1, 0)); day = day.AddDate(0, 0, 1) {
+			if day.Month() != month.Month() {
+				days.Add(widget.NewLabel(""))
+				continue
+			}
+
+			day := day
+			b := widget.NewButton(fmt.Sprint(day.Day()), func() {
+				pick(day)
+			})
+			b.Importance = widget.LowImportance
+			if p.c.issueCached(p.pub, day) {
+				b.Importance = widget.MediumImportance
+			}
+			if WeekOf(day).Equal(p.week) {
+				b.Importance = widget.HighImportance
+			}
+			days.Add(b)
+		}
+
+		legend := widget.NewLabel("Shaded days are in weeks whose issue is cached")
+		legend.Wrapping = fyne.TextWrapWord
+
+		box.Objects = []fyne.CanvasObject{
+			container.NewBorder(nil, nil, prev, next, title),
+			days,
+			legend,
+			widget.NewButton("Close", cancel),
+		}
+		box.Refresh()
+	}
+	draw()
+
+	return box
+}
+
+// issueName names the issue of pub published in the month of issue
+func issueName(pub string, issue time.Time) string {
+	switch pub {
+	case "mwb":
+		return fmt.Sprintf("Meeting Workbook, %s–%s", issue.Format("January"), issue.AddDate(0, 1, 0).Format("January 2006"))
+	case "w":
+		return "Watchtower Study Edition, " + issue.Format("January 2006")
+	}
+	return pub + " " + issue.Format("January 2006")
+}
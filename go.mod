@@ -10,4 +10,5 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.6
 	github.com/pelletier/go-toml v1.8.1
 	github.com/sirupsen/logrus v1.8.1
+	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
 )
@@ -1,178 +1,797 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/meeting"
+	"meeting-media/playlist"
 )
 
-func (c *Config) mGUI(m string) *fyne.Container {
+// mGUI is the tab of meeting m in window w; its fetch runs as job
+func (c *Config) mGUI(w fyne.Window, m string, job *guiJob) *fyne.Container {
 
-	date := widget.NewEntry()
-	date.SetText(time.Now().Format("2006-01-02"))
+	week := c.newWeekPicker(m)
 
-	song1box := widget.NewEntry()
-	song1box.SetPlaceHolder("Song #1")
-	song2box := widget.NewEntry()
-	song2box.SetPlaceHolder("Song #2")
-	song3box := widget.NewEntry()
-	song3box.SetPlaceHolder("Song #3")
-
-	fetchOtherMedia := widget.NewCheck("Fetch other media (pictures & videos)", func(f bool) {
-		c.FetchOtherMedia = f
-		c.writeConfigToFile()
-	})
-	fetchOtherMedia.SetChecked(c.FetchOtherMedia)
+	songs := []*songBox{c.newSongBox("Song #1"), c.newSongBox("Song #2"), c.newSongBox("Song #3")}
 
+	// the songs of the program are filled in when fetching them automatically
 	if c.AutoFetchMeetingData {
 		if m == MM {
-			song1box.Disabled()
+			songs[0].entry.Disable()
 		}
-		song2box.Disabled()
-		song3box.Disabled()
-		fetchOtherMedia.Enable()
-	} else {
-		if m == MM {
-			song1box.Enable()
+		songs[1].entry.Disable()
+		songs[2].entry.Disable()
+	}
+
+	excludedLabel := widget.NewLabel("")
+
+	fetch := func() {
+		c.Date = week.week
+		c.SongsToGet = nil
+		for _, s := range songs {
+			num, _ := meeting.ParseSongNumber(s.entry.Text)
+			c.SongsToGet = append(c.SongsToGet, num)
+		}
+
+		weekOf := c.Date.Format("2006-01-02")
+		logrus.Infof("fetching %s for the week of %s", m, weekOf)
+
+		var items []playlist.Item
+		err := job.start(func(ctx context.Context) (err error) {
+			c.preFetchHooks(ctx, m)
+			items, err = c.gatherMedia(ctx, m)
+			if err != nil && ctx.Err() == nil {
+				c.postFetchHooks(ctx, m, nil, err)
+			}
+			return
+		}, func(err error) {
+			excludedLabel.SetText(c.Report.String())
+			week.set(week.week) // the issue may be cached now
+			// what the review saves, since c may be changed again before it is saved
+			date, songsToGet := c.Date, c.SongsToGet
+			if err == nil && c.AutoFetchMeetingData {
+				for i, s := range songs {
+					if s.entry.Disabled() && i < len(c.SongsToGet) {
+						s.entry.SetText(c.SongsToGet[i])
+					}
+				}
+			}
+
+			// reset in case of subsequent runs
+			c.Report = meeting.Report{}
+			c.SongsToGet = []string{}
+
+			if err != nil {
+				notifyResult(err, "")
+				return
+			}
+
+			// nothing goes into the download folder until the items have been reviewed,
+			// and no other fetch can start until then
+			c.reviewGUI("Review "+m+" "+weekOf, items, job, func(items []playlist.Item) error {
+				return job.start(func(ctx context.Context) error {
+					c.Date, c.SongsToGet = date, songsToGet
+					err := c.saveMedia(m, items)
+					c.postFetchHooks(ctx, m, items, err)
+					return err
+				}, func(err error) {
+					excludedLabel.SetText(c.Report.HooksSummary())
+					c.Report = meeting.Report{}
+					c.SongsToGet = []string{}
+					notifyResult(err, songsSummary(items))
+				})
+			})
+		})
+		if err != nil {
+			dialog.ShowError(err, w)
 		}
-		song2box.Enable()
-		song3box.Enable()
-		fetchOtherMedia.Disable()
 	}
 
-	autoFetchMeetingData := widget.NewCheck("Automatically fetch meeting data", func(f bool) {
-		c.AutoFetchMeetingData = f
-		c.writeConfigToFile()
-		if f {
-			if m == MM {
-				song1box.Disable()
+	// typed songs are fetched once their titles have been confirmed
+	fetchButton := widget.NewButton("Fetch", func() {
+		var typed []string
+		for _, s := range songs {
+			if s.entry.Disabled() || strings.TrimSpace(s.entry.Text) == "" {
+				continue
 			}
-			song2box.Disable()
-			song3box.Disable()
-			fetchOtherMedia.Enable()
-		} else {
-			if m == MM {
-				song1box.Enable()
+			if err := s.entry.Validate(); err != nil {
+				dialog.ShowError(err, w)
+				return
 			}
-			song2box.Enable()
-			song3box.Enable()
-			fetchOtherMedia.Disable()
+			typed = append(typed, s.title.Text)
+		}
+		if len(typed) == 0 {
+			fetch()
+			return
 		}
+		dialog.ShowConfirm("Fetch these songs?", strings.Join(typed, "\n"), func(ok bool) {
+			if ok {
+				fetch()
+			}
+		}, w)
 	})
-	autoFetchMeetingData.SetChecked(c.AutoFetchMeetingData)
+	job.lock(fetchButton)
 
-	playlistOption := widget.NewCheck("Create Playlist", func(p bool) {
-		c.CreatePlaylist = p
-		c.writeConfigToFile()
-	})
-	playlistOption.SetChecked(c.CreatePlaylist)
+	mmBox := container.NewVBox(week.widget())
+	for _, s := range songs {
+		mmBox.Add(container.NewGridWithColumns(2, s.entry, s.title))
+	}
+	mmBox.Add(fetchButton)
+	mmBox.Add(excludedLabel)
 
-	fetchButton := widget.NewButton("Fetch", func() {
-		dateToSet, err := time.Parse("2006-01-02", date.Text)
+	return mmBox
+}
+
+// songBox takes a song number, and shows the title of the song next to it once it is known
+type songBox struct {
+	c     *Config
+	entry *widget.Entry
+	title *widget.Label
+
+	mu  sync.Mutex
+	num string // the number whose title is being shown
+}
+
+func (c *Config) newSongBox(placeHolder string) *songBox {
+	s := &songBox{c: c, entry: widget.NewEntry(), title: widget.NewLabel("")}
+	s.entry.SetPlaceHolder(placeHolder)
+	s.entry.Validator = func(text string) error {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		_, err := meeting.ParseSongNumber(text)
+		return err
+	}
+	s.entry.OnChanged = s.lookup
+	return s
+}
+
+// lookup shows the title of the song in the entry; the title of a number that has been typed over is dropped
+func (s *songBox) lookup(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.num = ""
+	if strings.TrimSpace(text) == "" {
+		s.title.SetText("")
+		return
+	}
+	num, err := meeting.ParseSongNumber(text)
+	if err != nil {
+		s.title.SetText(err.Error())
+		return
+	}
+	s.num = num
+	s.title.SetText(meeting.SongLabel(num, "") + ": looking up the title...")
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cdn.MetadataTimeout)
+		defer cancel()
+		title, err := s.c.fetcher().SongTitle(ctx, num)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.num != num {
+			return
+		}
 		if err != nil {
-			logrus.Fatal(err)
+			s.title.SetText(meeting.SongLabel(num, "") + ": title unknown")
+			logrus.Warnf("no title for song %s: %v", num, err)
+			return
 		}
-		c.Date = WeekOf(dateToSet)
-		c.SongsToGet = []string{song1box.Text, song2box.Text, song3box.Text}
+		s.title.SetText(meeting.SongLabel(num, title))
+	}()
+}
 
-		if err := c.fetchMeetingStuff(m); err == nil {
-			fyne.CurrentApp().SendNotification(&fyne.Notification{
-				Title:   "Meeting Downloader",
-				Content: "SUCCESS!",
-			})
-		} else {
-			fyne.CurrentApp().SendNotification(&fyne.Notification{
-				Title:   "Meeting Downloader",
-				Content: "FAIL!",
-			})
+// notifyResult tells how a fetch went, since the window may not be in view; detail is added on success
+func notifyResult(err error, detail string) {
+	content := "SUCCESS!"
+	if errors.Is(err, context.Canceled) {
+		logrus.Info("fetch cancelled")
+		content = "Cancelled"
+	} else if err != nil {
+		logrus.Error(err)
+		content = "FAIL!"
+	} else {
+		logrus.Info("fetch finished")
+		if detail != "" {
+			logrus.Info(strings.ReplaceAll(detail, "\n", ", "))
+			content += "\n" + detail
 		}
+	}
+	fyne.CurrentApp().SendNotification(&fyne.Notification{
+		Title:   "Meeting Downloader",
+		Content: content,
+	})
+}
 
-		// reset in case of subsequent runs
-		c.Pictures = []file{}
-		c.Videos = []video{}
-		c.SongsToGet = []string{}
-		c.SongsNames = []string{}
+const noProfile = "(no profile)"
+
+// settingsGUI shows every setting of the active profile. Choices are saved right away;
+// typed settings are saved with the Save button once they are valid.
+// reload is called when the other tabs have to be rebuilt, and nothing can be changed while job runs.
+func (c *Config) settingsGUI(w fyne.Window, reload func(), job *guiJob) fyne.CanvasObject {
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	profileSelect := widget.NewSelect(append([]string{noProfile}, c.profileNames()...), func(name string) {
+		if name == noProfile {
+			name = ""
+		}
+		if name == c.Profile {
+			return
+		}
+		if err := c.switchProfile(name); err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		reload()
 	})
+	if c.Profile == "" {
+		profileSelect.SetSelected(noProfile)
+	} else {
+		profileSelect.SetSelected(c.Profile)
+	}
 
-	mmBox := container.NewVBox(
-		date,
-		autoFetchMeetingData,
-		fetchOtherMedia,
-		song1box,
-		song2box,
-		song3box,
-		playlistOption,
-		fetchButton,
-		c.Progress.ProgressBar,
+	newProfile := widget.NewEntry()
+	newProfile.SetPlaceHolder("New profile name...")
+	addProfile := widget.NewButton("Add Profile", func() {
+		if err := c.addProfile(strings.TrimSpace(newProfile.Text)); err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		reload()
+	})
+	profileBox := container.NewGridWithColumns(2,
+		profileSelect,
+		container.NewBorder(nil, nil, nil, addProfile, newProfile),
 	)
 
-	return mmBox
-}
+	fetchOtherMedia := widget.NewCheck("Fetch other media (pictures & videos)", func(f bool) {
+		if f != c.FetchOtherMedia {
+			c.FetchOtherMedia = f
+			c.writeConfigToFile()
+		}
+	})
+	fetchOtherMedia.SetChecked(c.FetchOtherMedia)
+
+	autoFetchMeetingData := widget.NewCheck("Automatically fetch meeting data", func(f bool) {
+		if f == c.AutoFetchMeetingData {
+			return
+		}
+		c.AutoFetchMeetingData = f
+		c.writeConfigToFile()
+		// the song boxes of the meeting tabs depend on it
+		reload()
+	})
+	autoFetchMeetingData.SetChecked(c.AutoFetchMeetingData)
+	if !c.AutoFetchMeetingData {
+		// other media are only found in the meeting data
+		fetchOtherMedia.Disable()
+	}
+
+	offline := widget.NewCheck("Offline: use only the cache and the publication folder", func(o bool) {
+		if o != c.Offline {
+			c.Offline = o
+			c.writeConfigToFile()
+		}
+	})
+	offline.SetChecked(c.Offline)
+
+	playlistOption := widget.NewCheck("Create playlist", func(p bool) {
+		if p != c.CreatePlaylist {
+			c.CreatePlaylist = p
+			c.writeConfigToFile()
+		}
+	})
+	playlistOption.SetChecked(c.CreatePlaylist)
+
+	purgeDir := widget.NewCheck("Delete previous content before downloading new", func(d bool) {
+		if d != c.PurgeSaveDir {
+			c.PurgeSaveDir = d
+			c.writeConfigToFile()
+		}
+	})
+	purgeDir.SetChecked(c.PurgeSaveDir)
 
-func (c *Config) settingsGUI() *fyne.Container {
 	resPicker := widget.NewRadioGroup([]string{
 		RES240,
 		RES360,
 		RES480,
 		RES720,
+		AUDIO,
 	}, func(res string) {
-		c.Resolution = res
+		if res != "" && res != c.Resolution {
+			c.Resolution = res
+			c.writeConfigToFile()
+		}
 	})
+	resPicker.Horizontal = true
 	resPicker.SetSelected(c.Resolution)
 
+	outputPicker := widget.NewRadioGroup([]string{
+		SYMLINK,
+		HARDLINK,
+		COPY,
+	}, func(mode string) {
+		if mode != "" && mode != c.OutputMode {
+			c.OutputMode = mode
+			c.writeConfigToFile()
+		}
+	})
+	outputPicker.Horizontal = true
+	outputPicker.SetSelected(c.OutputMode)
+
+	save := widget.NewButton("Save", nil)
+	unsaved := func(string) {
+		status.SetText("Unsaved changes")
+		save.Importance = widget.HighImportance
+		save.Refresh()
+	}
+
 	targetDir := widget.NewEntry()
 	targetDir.SetPlaceHolder("Download Path...")
 	targetDir.SetText(c.SaveLocation)
+	targetDir.Validator = validateLocation
+	targetDir.OnChanged = unsaved
 
 	cacheDir := widget.NewEntry()
 	cacheDir.SetPlaceHolder("Cache Path...")
 	cacheDir.SetText(c.CacheLocation)
+	cacheDir.Validator = validateLocation
+	cacheDir.OnChanged = unsaved
 
-	purgeDir := widget.NewCheck("Delete previous content before downloading new", func(d bool) {
-		c.PurgeSaveDir = d
-	})
-	purgeDir.SetChecked(c.PurgeSaveDir)
+	pubDir := widget.NewEntry()
+	pubDir.SetPlaceHolder("Folder of .jwpub files for offline use (optional)")
+	pubDir.SetText(c.PublicationFolder)
+	pubDir.Validator = func(text string) error {
+		if text == "" {
+			return nil
+		}
+		return validateLocation(text)
+	}
+	pubDir.OnChanged = unsaved
 
 	lang := widget.NewEntry()
 	lang.SetPlaceHolder("MEPS Language Symbol (eg. E)")
 	lang.SetText(c.Language)
+	lang.Validator = validateLanguage
+	lang.OnChanged = unsaved
 
 	pubs := widget.NewEntry()
 	pubs.SetPlaceHolder("Linked publication symbols to allow (eg. th, rr)")
-	var pubSymbolString string
-	for i, s := range c.PubSymbols {
-		if i != 0 {
-			pubSymbolString += ", "
+	pubs.SetText(strings.Join(c.PubSymbols, ", "))
+	pubs.Validator = func(text string) error {
+		return validatePubSymbols(parsePubSymbols(text))
+	}
+	pubs.OnChanged = unsaved
+
+	var rules []string
+	for _, e := range c.Exclusions {
+		rules = append(rules, e.String())
+	}
+	exclusions := widget.NewMultiLineEntry()
+	exclusions.SetPlaceHolder("One rule per line (eg. pub th, filename *_univ_cnt_*.jpg)")
+	exclusions.SetText(strings.Join(rules, "\n"))
+	exclusions.Validator = func(text string) error {
+		_, err := meeting.ParseExclusions(text)
+		return err
+	}
+	exclusions.OnChanged = unsaved
+
+	proxy := widget.NewEntry()
+	proxy.SetPlaceHolder("http://proxy:8080 or socks5://proxy:1080 (optional)")
+	proxy.SetText(c.Proxy)
+	proxy.Validator = validateProxy
+	proxy.OnChanged = unsaved
+
+	caCertificates := widget.NewMultiLineEntry()
+	caCertificates.SetPlaceHolder("Extra CA certificate files (PEM), one per line (optional)")
+	caCertificates.SetText(strings.Join(c.CACertificates, "\n"))
+	caCertificates.Validator = func(text string) error {
+		for _, path := range parseLines(text) {
+			if err := validateCertificateFile(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	caCertificates.OnChanged = unsaved
+
+	hookEntry := func(hooks []string) *widget.Entry {
+		e := widget.NewMultiLineEntry()
+		e.SetPlaceHolder("Shell commands or URLs to post to, one per line (optional)")
+		e.SetText(strings.Join(hooks, "\n"))
+		e.Validator = func(text string) error {
+			for _, hook := range parseLines(text) {
+				if err := validateHook(hook); err != nil {
+					return err
+				}
+			}
+			return nil
 		}
-		pubSymbolString += s
+		e.OnChanged = unsaved
+		return e
 	}
-	pubs.SetText(pubSymbolString)
+	preFetchHooks := hookEntry(c.PreFetchHooks)
+	postFetchHooks := hookEntry(c.PostFetchHooks)
+
+	userAgent := widget.NewEntry()
+	userAgent.SetPlaceHolder("User agent")
+	userAgent.SetText(c.UserAgent)
+	userAgent.OnChanged = unsaved
+
+	requestsPerMinute := numberEntry(c.RequestsPerMinute, unsaved)
+	retries := numberEntry(c.Retries, unsaved)
+	connectTimeout := durationEntry(c.ConnectTimeout, unsaved)
+	retryWaitMin := durationEntry(c.RetryWaitMin, unsaved)
+	retryWaitMax := durationEntry(c.RetryWaitMax, unsaved)
+
+	if errs := c.validate(); len(errs) > 0 {
+		status.SetText(errs.Error())
+	}
+
+	save.OnTapped = func() {
+		settings := *c
+		settings.SaveLocation = targetDir.Text
+		settings.CacheLocation = cacheDir.Text
+		settings.PublicationFolder = strings.TrimSpace(pubDir.Text)
+		settings.Language = lang.Text
+		settings.PubSymbols = parsePubSymbols(pubs.Text)
+		settings.Proxy = strings.TrimSpace(proxy.Text)
+		settings.CACertificates = parseLines(caCertificates.Text)
+		settings.UserAgent = strings.TrimSpace(userAgent.Text)
+		settings.PreFetchHooks = parseLines(preFetchHooks.Text)
+		settings.PostFetchHooks = parseLines(postFetchHooks.Text)
+		settings.ConnectTimeout = strings.TrimSpace(connectTimeout.Text)
+		settings.RetryWaitMin = strings.TrimSpace(retryWaitMin.Text)
+		settings.RetryWaitMax = strings.TrimSpace(retryWaitMax.Text)
+		settings.loadErrors = nil
 
-	save := widget.NewButton("Save", func() {
-		c.SaveLocation = targetDir.Text
-		c.CacheLocation = cacheDir.Text
-		c.Language = lang.Text
-		var pubSymbolSlice []string
-		for _, p := range strings.Split(pubs.Text, ",") {
-			pubSymbolSlice = append(pubSymbolSlice, strings.TrimSpace(strings.ToLower(p)))
+		var err error
+		if settings.RequestsPerMinute, err = strconv.Atoi(strings.TrimSpace(requestsPerMinute.Text)); err != nil {
+			status.SetText("Requests per minute: not a number")
+			return
 		}
-		c.PubSymbols = pubSymbolSlice
+		if settings.Retries, err = strconv.Atoi(strings.TrimSpace(retries.Text)); err != nil {
+			status.SetText("Retries: not a number")
+			return
+		}
+		if settings.Exclusions, err = meeting.ParseExclusions(exclusions.Text); err != nil {
+			status.SetText("Exclusions: " + err.Error())
+			return
+		}
+		if errs := settings.validate(); len(errs) > 0 {
+			status.SetText(errs.Error())
+			return
+		}
+
+		c.SaveLocation = settings.SaveLocation
+		c.CacheLocation = settings.CacheLocation
+		c.PublicationFolder = settings.PublicationFolder
+		c.Language = settings.Language
+		c.PubSymbols = settings.PubSymbols
+		c.Exclusions = settings.Exclusions
+		c.PreFetchHooks = settings.PreFetchHooks
+		c.PostFetchHooks = settings.PostFetchHooks
+		c.Proxy = settings.Proxy
+		c.CACertificates = settings.CACertificates
+		c.UserAgent = settings.UserAgent
+		c.RequestsPerMinute = settings.RequestsPerMinute
+		c.ConnectTimeout = settings.ConnectTimeout
+		c.Retries = settings.Retries
+		c.RetryWaitMin = settings.RetryWaitMin
+		c.RetryWaitMax = settings.RetryWaitMax
+		c.loadErrors = nil
 		c.writeConfigToFile()
+		if err := c.applyNetworkSettings(); err != nil {
+			status.SetText("Settings saved, but the network settings can't be used: " + err.Error())
+			return
+		}
+		status.SetText("Settings saved")
+		save.Importance = widget.MediumImportance
+		save.Refresh()
+	}
+
+	reset := widget.NewButton("Reset to Defaults", func() {
+		question := "Put every setting back to its default?"
+		if c.Profile != "" {
+			question = "Put the settings of profile " + c.Profile + " back to their defaults?\n" +
+				"The settings all profiles share, like the network settings, hooks and exclusions, are kept."
+		}
+		dialog.ShowConfirm("Reset settings", question, func(ok bool) {
+			if !ok {
+				return
+			}
+			c.resetSettings()
+			reload()
+		}, w)
 	})
 
-	settingsBox := container.NewVBox(
-		resPicker,
-		targetDir,
-		cacheDir,
-		purgeDir,
-		lang,
-		pubs,
-		save,
+	job.lock(profileSelect, addProfile, autoFetchMeetingData, offline, playlistOption, purgeDir, resPicker, outputPicker, save, reset)
+
+	form := widget.NewForm(
+		widget.NewFormItem("Profile", profileBox),
+		widget.NewFormItem("Meeting data", container.NewVBox(autoFetchMeetingData, fetchOtherMedia, offline)),
+		widget.NewFormItem("Output", container.NewVBox(playlistOption, purgeDir, outputPicker)),
+		widget.NewFormItem("Resolution", resPicker),
+		widget.NewFormItem("Download folder", folderEntry(w, targetDir)),
+		widget.NewFormItem("Cache folder", folderEntry(w, cacheDir)),
+		widget.NewFormItem("Publication folder", folderEntry(w, pubDir)),
+		widget.NewFormItem("Language", lang),
+		widget.NewFormItem("Publications", pubs),
+		widget.NewFormItem("Exclusions", exclusions),
+		widget.NewFormItem("Before fetching", preFetchHooks),
+		widget.NewFormItem("After fetching", postFetchHooks),
+		widget.NewFormItem("Proxy", proxy),
+		widget.NewFormItem("CA certificates", caCertificates),
+		widget.NewFormItem("User agent", userAgent),
+		widget.NewFormItem("Requests per minute", requestsPerMinute),
+		widget.NewFormItem("Connect timeout", connectTimeout),
+		widget.NewFormItem("Retries", retries),
+		widget.NewFormItem("Retry waits (min, max)", container.NewGridWithColumns(2, retryWaitMin, retryWaitMax)),
 	)
 
-	return settingsBox
+	return container.NewVBox(
+		form,
+		container.NewGridWithColumns(2, reset, save),
+		status,
+	)
+}
+
+// numberEntry is an entry for a setting that is a whole number, 0 or more
+func numberEntry(n int, onChanged func(string)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetText(strconv.Itoa(n))
+	entry.Validator = func(text string) error {
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		if err != nil {
+			return errors.New("not a number")
+		}
+		return validateNotNegative(n)
+	}
+	entry.OnChanged = onChanged
+	return entry
+}
+
+// durationEntry is an entry for a setting that is a duration, like 30s
+func durationEntry(d string, onChanged func(string)) *widget.Entry {
+	entry := widget.NewEntry()
+	entry.SetPlaceHolder("eg. 30s")
+	entry.SetText(d)
+	entry.Validator = func(text string) error {
+		return validateDuration(strings.TrimSpace(text))
+	}
+	entry.OnChanged = onChanged
+	return entry
+}
+
+// folderEntry adds a button to entry for choosing the folder in a dialog
+func folderEntry(w fyne.Window, entry *widget.Entry) fyne.CanvasObject {
+	browse := widget.NewButtonWithIcon("", theme.FolderOpenIcon(), func() {
+		d := dialog.NewFolderOpen(func(dir fyne.ListableURI, err error) {
+			if err != nil {
+				logrus.Warn(err)
+				return
+			}
+			if dir != nil {
+				entry.SetText(dir.Path())
+			}
+		}, w)
+		if start, err := storage.ListerForURI(storage.NewFileURI(entry.Text)); err == nil {
+			d.SetLocation(start)
+		}
+		d.Show()
+	})
+	return container.NewBorder(nil, nil, nil, browse, entry)
+}
+
+// guiProgress shows the current item and the whole fetch in two progress bars,
+// and writes how each item went to the log panel
+type guiProgress struct {
+	item  *widget.ProgressBar
+	total *widget.ProgressBar
+	log   *logPanel
+
+	mu   sync.Mutex
+	last cache.Event
+}
+
+func newGUIProgress() *guiProgress {
+	gp := &guiProgress{
+		item:  widget.NewProgressBar(),
+		total: widget.NewProgressBar(),
+		log:   newLogPanel(),
+	}
+	gp.item.TextFormatter = func() string {
+		gp.mu.Lock()
+		defer gp.mu.Unlock()
+		return gp.last.Item
+	}
+	gp.total.TextFormatter = func() string {
+		gp.mu.Lock()
+		defer gp.mu.Unlock()
+		return fmt.Sprintf("%d of %d", gp.last.Finished(), gp.last.Total)
+	}
+	return gp
+}
+
+func (gp *guiProgress) Report(e cache.Event) {
+	gp.mu.Lock()
+	gp.last = e
+	gp.mu.Unlock()
+
+	gp.item.SetValue(e.ItemFraction())
+	gp.total.SetValue(e.TotalFraction())
+
+	switch e.Event {
+	case cache.EventFinish:
+		gp.log.add(time.Now(), fmt.Sprintf("[%d/%d] %s done", e.Finished(), e.Total, e.Item))
+	case cache.EventError:
+		gp.log.add(time.Now(), fmt.Sprintf("[%d/%d] %s failed: %s", e.Finished(), e.Total, e.Item, e.Error))
+	}
+}
+
+// maxLogLines is how much of the log the panel keeps
+const maxLogLines = 500
+
+// logPanel shows the log in the window, since nobody sees the terminal of a GUI program.
+// It is a logrus hook, so it gets whatever the log level lets through.
+type logPanel struct {
+	text   *widget.Label
+	scroll *container.Scroll
+
+	mu    sync.Mutex
+	lines []string
+}
+
+func newLogPanel() *logPanel {
+	p := &logPanel{text: widget.NewLabel("")}
+	p.text.Wrapping = fyne.TextWrapWord
+	p.scroll = container.NewVScroll(p.text)
+	p.scroll.SetMinSize(fyne.NewSize(0, 150))
+	return p
+}
+
+func (p *logPanel) add(t time.Time, line string) {
+	p.mu.Lock()
+	p.lines = append(p.lines, t.Format("15:04:05")+" "+line)
+	if len(p.lines) > maxLogLines {
+		p.lines = p.lines[len(p.lines)-maxLogLines:]
+	}
+	text := strings.Join(p.lines, "\n")
+	p.mu.Unlock()
+
+	p.text.SetText(text)
+	p.scroll.ScrollToBottom()
+}
+
+func (p *logPanel) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (p *logPanel) Fire(e *logrus.Entry) error {
+	p.add(e.Time, strings.ToUpper(e.Level.String())+" "+e.Message)
+	return nil
+}
+
+// guiJob runs a fetch in the background. Only one runs at a time, and the controls
+// that would start another one or change the settings are disabled meanwhile, and while
+// a window that belongs to the fetch, like the review, holds them.
+type guiJob struct {
+	cancelButton *widget.Button
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	holds    int
+	controls []fyne.Disableable
+}
+
+var errJobRunning = errors.New("a job is already running")
+
+func newGUIJob() *guiJob {
+	j := &guiJob{}
+	j.cancelButton = widget.NewButton("Cancel", j.stop)
+	j.cancelButton.Disable()
+	return j
+}
+
+// lock disables controls whenever a job runs; they must not be disabled for other reasons
+func (j *guiJob) lock(controls ...fyne.Disableable) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.controls = append(j.controls, controls...)
+}
+
+// forget drops the controls, when the widgets they belong to are replaced
+func (j *guiJob) forget() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.controls = nil
+}
+
+// start calls run in the background, then done with its result. It fails when a job runs already.
+func (j *guiJob) start(run func(ctx context.Context) error, done func(err error)) error {
+	j.mu.Lock()
+	if j.cancel != nil {
+		j.mu.Unlock()
+		return errJobRunning
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	controls := j.controls
+	j.mu.Unlock()
+
+	for _, w := range controls {
+		w.Disable()
+	}
+	j.cancelButton.Enable()
+
+	go func() {
+		err := run(ctx)
+		cancel()
+		done(err)
+
+		j.mu.Lock()
+		j.cancel = nil
+		j.mu.Unlock()
+		j.cancelButton.Disable()
+		j.unlock()
+	}()
+	return nil
+}
+
+// hold keeps the controls disabled, after the job that runs too, until release is called
+func (j *guiJob) hold() (release func()) {
+	j.mu.Lock()
+	j.holds++
+	j.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			j.mu.Lock()
+			j.holds--
+			j.mu.Unlock()
+			j.unlock()
+		})
+	}
+}
+
+// unlock enables the controls once no job runs and nothing holds them
+func (j *guiJob) unlock() {
+	j.mu.Lock()
+	idle := j.cancel == nil && j.holds == 0
+	controls := j.controls
+	j.mu.Unlock()
+
+	if idle {
+		for _, w := range controls {
+			w.Enable()
+		}
+	}
+}
+
+func (j *guiJob) stop() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.cancel != nil {
+		j.cancel()
+	}
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// idle waits for the job that runs to end
+func (j *guiJob) idle(t *testing.T) {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		j.mu.Lock()
+		running := j.cancel != nil
+		j.mu.Unlock()
+		if !running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job never ended")
+}
+
+func TestGUIJobHold(t *testing.T) {
+	j := newGUIJob()
+	fetch := widget.NewButton("Fetch", nil)
+	j.lock(fetch)
+
+	var release func()
+	err := j.start(func(ctx context.Context) error { return nil }, func(err error) {
+		release = j.hold() // the review opens
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.idle(t)
+	if !fetch.Disabled() {
+		t.Error("fetch enabled while the review is open")
+	}
+
+	// the review saves; nothing else starts meanwhile
+	saving := make(chan struct{})
+	if err := j.start(func(ctx context.Context) error { <-saving; return nil }, func(error) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.start(func(ctx context.Context) error { return nil }, func(error) {}); err != errJobRunning {
+		t.Errorf("second job: %v", err)
+	}
+	release() // the review closes
+	if !fetch.Disabled() {
+		t.Error("fetch enabled while saving")
+	}
+	close(saving)
+	j.idle(t)
+	for i := 0; i < 100 && fetch.Disabled(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if fetch.Disabled() {
+		t.Error("fetch still disabled")
+	}
+}
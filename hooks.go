@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/playlist"
+)
+
+// stages of a fetch that hooks run at
+const (
+	preFetch  = "pre"
+	postFetch = "post"
+)
+
+// hookTimeout is how long a hook may take before it is stopped
+const hookTimeout = 2 * time.Minute
+
+// fetchManifest describes a fetch to its hooks. Commands read it from stdin, or from the file
+// named by $MEETING_MEDIA_MANIFEST; URLs get it as the body of a POST.
+type fetchManifest struct {
+	Stage    string
+	Meeting  string
+	Week     string
+	Folder   string
+	Playlist string        `json:",omitempty"` // only after a fetch that wrote one
+	Files    []fetchedFile `json:",omitempty"`
+	Error    string        `json:",omitempty"` // why the fetch failed
+}
+
+type fetchedFile struct {
+	Name  string
+	Kind  string
+	Title string `json:",omitempty"`
+	Part  string `json:",omitempty"`
+}
+
+// isHookURL tells webhooks from commands
+func isHookURL(hook string) bool {
+	return strings.HasPrefix(hook, "http://") || strings.HasPrefix(hook, "https://")
+}
+
+func validateHook(hook string) error {
+	if strings.TrimSpace(hook) == "" {
+		return errors.New("a command or URL is required")
+	}
+	if !isHookURL(hook) {
+		return nil
+	}
+	u, err := url.Parse(hook)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return errors.New("URL has no host")
+	}
+	if !isLoopback(u.Hostname()) {
+		return fmt.Errorf("%s is not this computer; hooks can only post to localhost", u.Hostname())
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// preFetchHooks runs the PreFetchHooks before meeting m of the week of c.Date is fetched
+func (c *Config) preFetchHooks(ctx context.Context, m string) {
+	c.runHooks(ctx, c.PreFetchHooks, c.manifest(preFetch, m, nil, nil))
+}
+
+// postFetchHooks runs the PostFetchHooks once meeting m was fetched into SaveLocation, or failed with err
+func (c *Config) postFetchHooks(ctx context.Context, m string, items []playlist.Item, err error) {
+	c.runHooks(ctx, c.PostFetchHooks, c.manifest(postFetch, m, items, err))
+}
+
+func (c *Config) manifest(stage, m string, items []playlist.Item, err error) fetchManifest {
+	manifest := fetchManifest{
+		Stage:   stage,
+		Meeting: m,
+		Week:    WeekOf(c.Date).Format("2006-01-02"),
+		Folder:  c.SaveLocation,
+	}
+	if err != nil {
+		manifest.Error = err.Error()
+		return manifest
+	}
+
+	for _, it := range items {
+		if it.Include {
+			manifest.Files = append(manifest.Files, fetchedFile{Name: it.Name, Kind: it.Kind, Title: it.Title, Part: it.Part})
+		}
+	}
+	if stage == postFetch && c.CreatePlaylist {
+		manifest.Playlist = filepath.Join(c.SaveLocation, playlist.File)
+	}
+	return manifest
+}
+
+// runHooks runs hooks one after the other, and adds those that fail to c.Report.
+// A failed hook doesn't stop the fetch or the hooks after it.
+func (c *Config) runHooks(ctx context.Context, hooks []string, manifest fetchManifest) {
+	if len(hooks) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	// commands get the path of the manifest, which is only kept while the hooks run
+	f, err := os.CreateTemp("", "meeting-media-*.json")
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logrus.Warn(err)
+		return
+	}
+
+	for _, hook := range hooks {
+		logrus.Infof("running %s-fetch hook %s", manifest.Stage, hook)
+		if isHookURL(hook) {
+			err = postHook(ctx, hook, data)
+		} else {
+			err = commandHook(ctx, hook, f.Name(), data)
+		}
+		if err != nil {
+			logrus.Warnf("%s-fetch hook %s failed: %v", manifest.Stage, hook, err)
+			c.Report.Hooks = append(c.Report.Hooks, fmt.Sprintf("%s (%s-fetch): %v", hook, manifest.Stage, err))
+		}
+	}
+}
+
+// commandHook runs command in the shell with the manifest on stdin
+func commandHook(ctx context.Context, command, manifestPath string, manifest []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), "MEETING_MEDIA_MANIFEST="+manifestPath)
+	cmd.Stdin = bytes.NewReader(manifest)
+
+	output, err := cmd.CombinedOutput()
+	logrus.Debugf("hook output: %s", output)
+	if err != nil {
+		if last := lastLine(output); last != "" {
+			return fmt.Errorf("%v: %s", err, last)
+		}
+		return err
+	}
+	return nil
+}
+
+// hookClient posts to the webhooks; they are local services, which are not reached through the proxy
+var hookClient = &http.Client{Transport: &http.Transport{Proxy: nil}}
+
+// postHook posts the manifest to hookURL
+func postHook(ctx context.Context, hookURL string, manifest []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, hookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hookURL, bytes.NewReader(manifest))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := hookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New(resp.Status)
+	}
+	return nil
+}
+
+// lastLine is the last line a command printed, which usually says why it failed
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,21 @@
+package main
+
+import "testing"
+
+func TestValidateHook(t *testing.T) {
+	for hook, ok := range map[string]bool{
+		"notify-send fetched":               true,
+		"http://localhost:8080/fetched":     true,
+		"http://127.0.0.1/fetched":          true,
+		"http://[::1]:8080/fetched":         true,
+		"https://example.com/fetched":       false,
+		"http://192.168.1.10/fetched":       false,
+		"http://localhost.example.com/hook": false,
+		"http:///fetched":                   false,
+		"   ":                               false,
+	} {
+		if err := validateHook(hook); (err == nil) != ok {
+			t.Errorf("%q: %v", hook, err)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"meeting-media/jwpub"
+)
+
+// inspectTables are the tables of a JWPUB the meeting queries read
+var inspectTables = []string{"Document", "DatedText", "Multimedia", "DocumentMultimedia", "Extract", "DocumentExtract", "RefPublication"}
+
+// maxCellWidth keeps the tables readable; JSON has the whole values
+const maxCellWidth = 40
+
+// inspectReport is what inspect found in a JWPUB
+type inspectReport struct {
+	File     string
+	Tables   []jwpub.Table
+	Selected *inspectSelection `json:",omitempty"`
+}
+
+// inspectSelection is what the meeting queries select for a week, or the errors they run into
+type inspectSelection struct {
+	Pub             string
+	Week            string
+	Weeks           []string `json:",omitempty"` // weeks there is a meeting for in the issue
+	Documents       []int    `json:",omitempty"`
+	Songs           []string `json:",omitempty"`
+	Pictures        []string `json:",omitempty"`
+	Videos          []string `json:",omitempty"`
+	LinkedDocuments []string `json:",omitempty"`
+	Errors          []string `json:",omitempty"`
+}
+
+// inspectJWPub lists tables of a JWPUB file, and what the meeting queries of pub select for week
+func (c *Config) inspectJWPub(jwpubBytes []byte, name, pub string, week time.Time, tables []string) (report inspectReport, err error) {
+	j, err := jwpub.Open(jwpubBytes, "*.db")
+	if err != nil {
+		return
+	}
+	defer j.Close()
+
+	report.File = name
+	for _, t := range tables {
+		report.Tables = append(report.Tables, j.Table(t))
+	}
+
+	switch pub {
+	case "mwb":
+		report.Selected = c.selectMWB(j, week)
+	case "w":
+		report.Selected = selectWT(j, week)
+	}
+	return
+}
+
+func (c *Config) selectMWB(pub *jwpub.Publication, week time.Time) *inspectSelection {
+	s := &inspectSelection{Pub: "mwb", Week: week.Format("2006-01-02")}
+
+	docs, err := pub.MWBDocuments()
+	if err != nil {
+		s.fail("documents", err)
+		return s
+	}
+	for _, doc := range docs {
+		w := doc.Date.Format("2006-01-02")
+		if !doc.Date.IsZero() && (len(s.Weeks) == 0 || s.Weeks[len(s.Weeks)-1] != w) {
+			s.Weeks = append(s.Weeks, w)
+		}
+	}
+
+	// the same selection as a fetch
+	docGroups, err := pub.MWBWeek(week)
+	if err != nil {
+		s.fail("documents", err)
+		return s
+	}
+	for _, doc := range docGroups {
+		s.Documents = append(s.Documents, doc.ID)
+	}
+	if len(docGroups) == 0 {
+		return s
+	}
+
+	s.Songs, err = pub.MWBSongs(docGroups)
+	s.fail("songs", err)
+
+	images, err := pub.Images(docGroups)
+	s.fail("pictures", err)
+	for _, image := range images {
+		s.Pictures = append(s.Pictures, describePicture(image))
+	}
+
+	videos, err := pub.MWBVideos(docGroups)
+	s.fail("videos", err)
+	for _, v := range videos {
+		s.Videos = append(s.Videos, describeVideo(v))
+	}
+
+	linked, err := pub.LinkedDocuments(docGroups, c.PubSymbols)
+	s.fail("linked documents", err)
+	for _, ld := range linked {
+		s.LinkedDocuments = append(s.LinkedDocuments, fmt.Sprintf("%s document %d", ld.PublicationSymbol, ld.MepsDocumentID))
+	}
+	return s
+}
+
+func selectWT(pub *jwpub.Publication, week time.Time) *inspectSelection {
+	s := &inspectSelection{Pub: "w", Week: week.Format("2006-01-02")}
+
+	dates, err := pub.WTDates()
+	if err != nil {
+		s.fail("dates", err)
+		return s
+	}
+	for _, d := range dates {
+		s.Weeks = append(s.Weeks, d.Format("2006-01-02"))
+	}
+
+	doc, err := pub.WTStudy(week)
+	if err != nil {
+		s.fail("documents", err)
+		return s
+	}
+	if doc == 0 {
+		return s
+	}
+	s.Documents = []int{doc}
+
+	s.Songs, err = pub.WTSongs(week)
+	s.fail("songs", err)
+
+	images, err := pub.Images([]jwpub.Document{{ID: doc}})
+	s.fail("pictures", err)
+	for _, image := range images {
+		s.Pictures = append(s.Pictures, describePicture(image))
+	}
+	return s
+}
+
+// fail records the error of a query, if there is one
+func (s *inspectSelection) fail(query string, err error) {
+	if err != nil {
+		s.Errors = append(s.Errors, query+": "+err.Error())
+	}
+}
+
+func describePicture(f jwpub.Picture) string {
+	text := fmt.Sprintf("%s (document %d)", f.Name, f.DocumentMepsID)
+	if f.Caption != "" {
+		text += " " + f.Caption
+	}
+	return text
+}
+
+func describeVideo(v jwpub.Video) string {
+	var parts []string
+	if v.KeySymbol.Valid {
+		parts = append(parts, v.KeySymbol.String)
+	}
+	if v.IssueTagNumber != 0 {
+		parts = append(parts, fmt.Sprintf("issue %d", v.IssueTagNumber))
+	}
+	if v.MepsDocumentID.Valid {
+		parts = append(parts, fmt.Sprintf("document %d", v.MepsDocumentID.Int64))
+	}
+	if v.Track.Valid {
+		parts = append(parts, fmt.Sprintf("track %d", v.Track.Int64))
+	}
+	return strings.Join(parts, " ") + fmt.Sprintf(" (in document %d)", v.DocumentMepsID)
+}
+
+func (r inspectReport) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(r)
+}
+
+func (r inspectReport) writeTables(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, t := range r.Tables {
+		if t.Error != "" {
+			fmt.Fprintf(tw, "== %s: %s\n\n", t.Name, t.Error)
+			continue
+		}
+		fmt.Fprintf(tw, "== %s (%d rows)\n", t.Name, len(t.Rows))
+		fmt.Fprintln(tw, strings.Join(t.Columns, "\t"))
+		for _, row := range t.Rows {
+			cells := make([]string, len(row))
+			for i, v := range row {
+				cells[i] = cellText(v)
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+		// flush per table, so the columns of one don't widen those of the next
+		fmt.Fprintln(tw)
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	s := r.Selected
+	if s == nil {
+		return nil
+	}
+	fmt.Fprintf(w, "== Selected by the %s queries for the week of %s\n", s.Pub, s.Week)
+	fmt.Fprintf(w, "Weeks in issue: %s\n", strings.Join(s.Weeks, ", "))
+	if len(s.Documents) == 0 {
+		fmt.Fprintln(w, "No documents for this week")
+	} else {
+		fmt.Fprintf(w, "Documents: %s\n", strings.Trim(fmt.Sprint(s.Documents), "[]"))
+	}
+	fmt.Fprintf(w, "Songs: %s\n", strings.Join(s.Songs, ", "))
+	for _, list := range []struct {
+		name  string
+		items []string
+	}{{"Pictures", s.Pictures}, {"Videos", s.Videos}, {"Linked documents", s.LinkedDocuments}, {"Errors", s.Errors}} {
+		if len(list.items) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "%s:\n", list.name)
+		for _, item := range list.items {
+			fmt.Fprintf(w, "  %s\n", item)
+		}
+	}
+	return nil
+}
+
+// cellText is a value as shown in a table
+func cellText(v interface{}) string {
+	var text string
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case time.Time:
+		text = v.Format("2006-01-02 15:04:05")
+	default:
+		text = fmt.Sprint(v)
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if r := []rune(text); len(r) > maxCellWidth {
+		text = string(r[:maxCellWidth-1]) + "…"
+	}
+	return text
+}
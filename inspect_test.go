@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInspectSelectsLikeFetch(t *testing.T) {
+	server := newTestCDN(t)
+	resp, err := server.Client().Get(server.URL + "/files/mwb_E_202609.jwpub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the week as a local time, which the fetch selects by its day
+	week := time.Date(2026, 9, 7, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+	report, err := newTestConfig(t, server).inspectJWPub(data, "mwb_E_202609.jwpub", "mwb", week, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := report.Selected
+	if !reflect.DeepEqual(s.Weeks, []string{"2026-09-07", "2026-09-14"}) || !reflect.DeepEqual(s.Documents, []int{1}) {
+		t.Errorf("weeks %v, documents %v", s.Weeks, s.Documents)
+	}
+	if len(s.Songs) != 3 || len(s.Errors) > 0 {
+		t.Errorf("songs %v, errors %v", s.Songs, s.Errors)
+	}
+}
@@ -0,0 +1,231 @@
+package jwtest
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CDN imitates the parts of jw-cdn the fetcher uses: GETPUBMEDIALINKS for publications, songs
+// and videos, media-items for videos of publications, and the files they link to.
+// Requests for anything that wasn't added get 404. Every answer has an ETag, and a request
+// that sends it back in If-None-Match gets 304 Not Modified.
+type CDN struct {
+	*httptest.Server
+	Language    string
+	Resolutions []string // labels of the files of the videos added next, in the order GETPUBMEDIALINKS lists them
+
+	mu          sync.Mutex
+	files       map[string][]byte      // by path
+	links       map[string]interface{} // GETPUBMEDIALINKS answers, by linksKey
+	items       map[string]interface{} // media-items answers, by path
+	requests    []string
+	notModified int
+}
+
+// NewCDN starts a CDN serving media in language lang; Close it when done
+func NewCDN(lang string) *CDN {
+	c := &CDN{
+		Language:    lang,
+		Resolutions: []string{"240p", "360p", "480p", "720p"},
+		files:       make(map[string][]byte),
+		links:       make(map[string]interface{}),
+		items:       make(map[string]interface{}),
+	}
+	c.Server = httptest.NewServer(http.HandlerFunc(c.serve))
+	return c
+}
+
+// Transport sends the requests for every host to the CDN, so the fetcher can use its real URLs
+func (c *CDN) Transport() http.RoundTripper {
+	target, _ := url.Parse(c.URL)
+	next := c.Client().Transport
+	return roundTripper(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = target.Scheme
+		req.URL.Host = target.Host
+		req.Host = target.Host
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Requests lists the path and query of every request so far
+func (c *CDN) Requests() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.requests...)
+}
+
+// NotModified counts the requests that got 304 Not Modified
+func (c *CDN) NotModified() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.notModified
+}
+
+// AddFile serves data under name and returns its URL and checksum
+func (c *CDN) AddFile(name string, data []byte) (fileURL, checksum string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.files["/files/"+name] = data
+	return "https://b.jw-cdn.org/files/" + name, fmt.Sprintf("%x", md5.Sum(data))
+}
+
+// AddJWPUB builds p and serves it, and its GETPUBMEDIALINKS answer
+func (c *CDN) AddJWPUB(p Publication) error {
+	data, err := p.Build()
+	if err != nil {
+		return err
+	}
+	fileURL, checksum := c.AddFile(p.FileName(), data)
+
+	q := url.Values{"pub": {p.Symbol}, "fileformat": {"jwpub"}}
+	if p.IssueTagNumber != 0 {
+		q.Set("issue", fmt.Sprint(p.IssueTagNumber/100))
+	}
+	c.addLinks(q, "JWPUB", []interface{}{linkFile(fileURL, checksum, len(data), "", 0)})
+	return nil
+}
+
+// AddSong serves song track with title, as mp4 in every resolution and as mp3
+func (c *CDN) AddSong(track int, title string, data []byte) {
+	name := fmt.Sprintf("sjjm_%s_%03d", c.Language, track)
+	var mp4 []interface{}
+	for _, res := range c.Resolutions {
+		fileURL, checksum := c.AddFile(fmt.Sprintf("%s_r%s.mp4", name, strings.ToUpper(res)), data)
+		mp4 = append(mp4, linkFile(fileURL, checksum, len(data), fmt.Sprintf("%d. %s", track, title), track))
+	}
+	c.addLinks(url.Values{"pub": {"sjjm"}, "track": {fmt.Sprint(track)}, "fileformat": {"mp4"}}, "MP4", mp4)
+
+	fileURL, checksum := c.AddFile(name+".mp3", data)
+	mp3 := []interface{}{linkFile(fileURL, checksum, len(data), fmt.Sprintf("%d. %s", track, title), track)}
+	c.addLinks(url.Values{"pub": {"sjjm"}, "track": {fmt.Sprint(track)}, "fileformat": {"mp3"}}, "MP3", mp3)
+}
+
+// AddDocVideo serves a video found by the MEPS id of its document, like the videos of the workbook
+func (c *CDN) AddDocVideo(docID, track int, title string, data []byte) {
+	var mp4 []interface{}
+	for _, res := range c.Resolutions {
+		fileURL, checksum := c.AddFile(fmt.Sprintf("doc_%d_%d_r%s.mp4", docID, track, strings.ToUpper(res)), data)
+		mp4 = append(mp4, linkFile(fileURL, checksum, len(data), title, track))
+	}
+	c.addLinks(url.Values{"docid": {fmt.Sprint(docID)}, "track": {fmt.Sprint(track)}, "fileformat": {"mp4"}}, "MP4", mp4)
+}
+
+// AddPubVideo serves track of the publication symbol. Videos of an issue are found through media-items,
+// undated ones (issueTagNumber 0) through GETPUBMEDIALINKS.
+func (c *CDN) AddPubVideo(symbol string, issueTagNumber, track int, title string, data []byte) {
+	name := fmt.Sprintf("%s_%s_%d", symbol, c.Language, track)
+	if issueTagNumber != 0 {
+		name = fmt.Sprintf("%s_%s_%d_%d", symbol, c.Language, issueTagNumber/100, track)
+	}
+
+	if issueTagNumber == 0 {
+		var mp4 []interface{}
+		for _, res := range c.Resolutions {
+			fileURL, checksum := c.AddFile(fmt.Sprintf("%s_r%s.mp4", name, strings.ToUpper(res)), data)
+			mp4 = append(mp4, linkFile(fileURL, checksum, len(data), title, track))
+		}
+		c.addLinks(url.Values{"pub": {symbol}, "track": {fmt.Sprint(track)}, "fileformat": {"mp4"}}, "MP4", mp4)
+		return
+	}
+
+	var files []interface{}
+	for _, res := range c.Resolutions {
+		fileURL, checksum := c.AddFile(fmt.Sprintf("%s_r%s.mp4", name, strings.ToUpper(res)), data)
+		files = append(files, map[string]interface{}{
+			"progressiveDownloadURL": fileURL,
+			"checksum":               checksum,
+			"filesize":               len(data),
+			"label":                  res,
+			"subtitled":              false,
+		})
+	}
+	path := fmt.Sprintf("/apis/mediator/v1/media-items/%s/pub-%s_%d_%d_VIDEO", c.Language, symbol, issueTagNumber/100, track)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items[path] = map[string]interface{}{
+		"media": []interface{}{map[string]interface{}{"title": title, "files": files}},
+	}
+}
+
+func linkFile(fileURL, checksum string, size int, title string, track int) map[string]interface{} {
+	return map[string]interface{}{
+		"title":    title,
+		"track":    track,
+		"file":     map[string]string{"url": fileURL, "checksum": checksum},
+		"filesize": size,
+	}
+}
+
+func (c *CDN) addLinks(q url.Values, format string, files []interface{}) {
+	q.Set("langwritten", c.Language)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.links[linksKey(q)] = map[string]interface{}{
+		"files": map[string]interface{}{c.Language: map[string]interface{}{format: files}},
+	}
+}
+
+// linksKey is what tells GETPUBMEDIALINKS requests apart; output, alllangs and txtCMSLang don't
+func linksKey(q url.Values) string {
+	var key []string
+	for _, name := range []string{"pub", "issue", "docid", "track", "fileformat", "langwritten"} {
+		if v := q.Get(name); v != "" {
+			key = append(key, name+"="+strings.ToLower(v))
+		}
+	}
+	sort.Strings(key)
+	return strings.Join(key, "&")
+}
+
+func (c *CDN) serve(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	c.requests = append(c.requests, r.URL.RequestURI())
+	var answer interface{}
+	var data []byte
+	var found bool
+	switch {
+	case r.URL.Path == "/apis/pub-media/GETPUBMEDIALINKS":
+		answer, found = c.links[linksKey(r.URL.Query())]
+	case strings.HasPrefix(r.URL.Path, "/apis/mediator/"):
+		answer, found = c.items[r.URL.Path]
+	default:
+		data, found = c.files[r.URL.Path]
+	}
+	c.mu.Unlock()
+
+	if !found {
+		http.NotFound(w, r)
+		return
+	}
+	contentType := "application/octet-stream"
+	if answer != nil {
+		contentType = "application/json"
+		data, _ = json.Marshal(answer)
+	}
+
+	etag := fmt.Sprintf(`"%x"`, md5.Sum(data))
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		c.mu.Lock()
+		c.notModified++
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(data)
+}
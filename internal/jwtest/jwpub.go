@@ -0,0 +1,280 @@
+// Package jwtest builds synthetic JWPUB files and serves them, with songs and videos,
+// from a fake jw-cdn, so fetching can be tested without a network.
+package jwtest
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// contentMask is mixed into the hash of the publication card to get the key and IV of document contents
+const contentMask = "11cbb5587e32846d4c26790c633da289f66fe5842a3a585ce1bc3a294af5ada7"
+
+// Publication is a JWPUB with the tables the meeting queries read. Zero tracks, paragraphs and
+// MEPS ids of multimedia, and empty key symbols and captions, are stored as NULL like in real files.
+type Publication struct {
+	Symbol         string // like mwb or w
+	Language       string // like E
+	LanguageIndex  int    // MEPS language index, 0 for E
+	Year           int
+	IssueTagNumber int // like 20260900; 0 for undated publications
+
+	Documents          []Document
+	DatedTexts         []DatedText
+	Multimedia         []Multimedia
+	DocumentMultimedia []DocumentMultimedia
+	Extracts           []Extract
+	DocumentExtracts   []DocumentExtract
+	RefPublications    []RefPublication
+
+	// Files are put into the contents next to the database, like the pictures of real publications
+	Files map[string][]byte
+}
+
+type Document struct {
+	ID             int
+	MepsDocumentID int
+	Class          int // 106 for workbook weeks, 40 for study articles
+	Title          string
+	Content        string // HTML; encrypted like in real publications
+}
+
+type DatedText struct {
+	ID         int
+	DocumentID int
+	FirstDate  int // like 20260831
+	// the study articles of w keep the multimedia of their songs here
+	BeginParagraph int
+	EndParagraph   int
+}
+
+type Multimedia struct {
+	ID             int
+	MimeType       string
+	FilePath       string
+	Track          int
+	KeySymbol      string
+	MepsDocumentID int
+	IssueTagNumber int
+	Caption        string
+}
+
+type DocumentMultimedia struct {
+	ID             int
+	DocumentID     int
+	MultimediaID   int
+	BeginParagraph int
+}
+
+type Extract struct {
+	ID                int
+	RefMepsDocumentID int
+	RefPublicationID  int
+}
+
+type DocumentExtract struct {
+	DocumentID int
+	ExtractID  int
+}
+
+type RefPublication struct {
+	ID            int
+	UndatedSymbol string
+}
+
+// FileName is what jw.org calls the publication, like mwb_E_202609.jwpub
+func (p Publication) FileName() string {
+	return p.baseName() + ".jwpub"
+}
+
+func (p Publication) baseName() string {
+	if p.IssueTagNumber == 0 {
+		return fmt.Sprintf("%s_%s", p.Symbol, p.Language)
+	}
+	return fmt.Sprintf("%s_%s_%d", p.Symbol, p.Language, p.IssueTagNumber/100)
+}
+
+// card is what the key of the document contents is derived from
+func (p Publication) card() string {
+	card := fmt.Sprintf("%d_%s_%d", p.LanguageIndex, p.Symbol, p.Year)
+	if p.IssueTagNumber != 0 {
+		card += fmt.Sprintf("_%d", p.IssueTagNumber)
+	}
+	return card
+}
+
+var schema = []string{
+	`CREATE TABLE Publication (PublicationId INTEGER PRIMARY KEY, MepsLanguageIndex INTEGER, Symbol TEXT,
+		UndatedSymbol TEXT, Year INTEGER, IssueTagNumber INTEGER)`,
+	`CREATE TABLE Document (DocumentId INTEGER PRIMARY KEY, MepsDocumentId INTEGER, Class INTEGER, Title TEXT, Content BLOB)`,
+	`CREATE TABLE DatedText (DatedTextId INTEGER PRIMARY KEY, DocumentId INTEGER, FirstDateOffset INTEGER,
+		BeginParagraphOrdinal INTEGER, EndParagraphOrdinal INTEGER)`,
+	`CREATE TABLE Multimedia (MultimediaId INTEGER PRIMARY KEY, MimeType TEXT, FilePath TEXT, Track INTEGER,
+		KeySymbol TEXT, MepsDocumentId INTEGER, IssueTagNumber INTEGER, Caption TEXT)`,
+	`CREATE TABLE DocumentMultimedia (DocumentMultimediaId INTEGER PRIMARY KEY, DocumentId INTEGER, MultimediaId INTEGER,
+		BeginParagraphOrdinal INTEGER)`,
+	`CREATE TABLE Extract (ExtractId INTEGER PRIMARY KEY, RefMepsDocumentId INTEGER, RefPublicationId INTEGER)`,
+	`CREATE TABLE DocumentExtract (DocumentExtractId INTEGER PRIMARY KEY, DocumentId INTEGER, ExtractId INTEGER)`,
+	`CREATE TABLE RefPublication (RefPublicationId INTEGER PRIMARY KEY, UndatedSymbol TEXT)`,
+}
+
+// Build returns the JWPUB file
+func (p Publication) Build() ([]byte, error) {
+	dir, err := os.MkdirTemp("", "jwtest_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	dbName := filepath.Join(dir, p.baseName()+".db")
+	if err := p.writeDatabase(dbName); err != nil {
+		return nil, err
+	}
+	db, err := os.ReadFile(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{filepath.Base(dbName): db}
+	for name, data := range p.Files {
+		files[name] = data
+	}
+	contents, err := zipFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := fmt.Sprintf(`{"name":%q,"publication":{"symbol":%q,"year":%d,"issueId":%d}}`,
+		p.FileName(), p.Symbol, p.Year, p.IssueTagNumber)
+	return zipFiles(map[string][]byte{"contents": contents, "manifest.json": []byte(manifest)})
+}
+
+func (p Publication) writeDatabase(name string) error {
+	db, err := sql.Open("sqlite3", name)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, query := range schema {
+		if _, err := db.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	exec := func(query string, args ...interface{}) {
+		if err == nil {
+			_, err = db.Exec(query, args...)
+		}
+	}
+	exec(`INSERT INTO Publication VALUES (1, ?, ?, ?, ?, ?)`, p.LanguageIndex, p.Symbol, p.Symbol, p.Year, p.IssueTagNumber)
+	for _, d := range p.Documents {
+		var content interface{}
+		if d.Content != "" && err == nil {
+			content, err = encrypt(p.card(), []byte(d.Content))
+		}
+		exec(`INSERT INTO Document VALUES (?, ?, ?, ?, ?)`, d.ID, d.MepsDocumentID, d.Class, d.Title, content)
+	}
+	for _, t := range p.DatedTexts {
+		exec(`INSERT INTO DatedText VALUES (?, ?, ?, ?, ?)`, t.ID, t.DocumentID, t.FirstDate, null(t.BeginParagraph), null(t.EndParagraph))
+	}
+	for _, m := range p.Multimedia {
+		exec(`INSERT INTO Multimedia VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, m.ID, m.MimeType, m.FilePath, null(m.Track),
+			nullString(m.KeySymbol), null(m.MepsDocumentID), m.IssueTagNumber, nullString(m.Caption))
+	}
+	for _, dm := range p.DocumentMultimedia {
+		exec(`INSERT INTO DocumentMultimedia VALUES (?, ?, ?, ?)`, dm.ID, dm.DocumentID, dm.MultimediaID, null(dm.BeginParagraph))
+	}
+	for _, e := range p.Extracts {
+		exec(`INSERT INTO Extract VALUES (?, ?, ?)`, e.ID, e.RefMepsDocumentID, e.RefPublicationID)
+	}
+	for i, de := range p.DocumentExtracts {
+		exec(`INSERT INTO DocumentExtract VALUES (?, ?, ?)`, i+1, de.DocumentID, de.ExtractID)
+	}
+	for _, r := range p.RefPublications {
+		exec(`INSERT INTO RefPublication VALUES (?, ?)`, r.ID, r.UndatedSymbol)
+	}
+	return err
+}
+
+func null(n int) interface{} {
+	if n == 0 {
+		return nil
+	}
+	return n
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// encrypt deflates and encrypts the content of a document the way JWPUB files do
+func encrypt(card string, content []byte) ([]byte, error) {
+	var deflated bytes.Buffer
+	w := zlib.NewWriter(&deflated)
+	if _, err := w.Write(content); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	plain := deflated.Bytes()
+	pad := aes.BlockSize - len(plain)%aes.BlockSize
+	plain = append(plain, bytes.Repeat([]byte{byte(pad)}, pad)...)
+
+	mask, err := hex.DecodeString(contentMask)
+	if err != nil {
+		return nil, err
+	}
+	secret := sha256.Sum256([]byte(card))
+	for i := range secret {
+		secret[i] ^= mask[i]
+	}
+	block, err := aes.NewCipher(secret[:16])
+	if err != nil {
+		return nil, err
+	}
+	encrypted := make([]byte, len(plain))
+	cipher.NewCBCEncrypter(block, secret[16:]).CryptBlocks(encrypted, plain)
+	return encrypted, nil
+}
+
+func zipFiles(files map[string][]byte) ([]byte, error) {
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
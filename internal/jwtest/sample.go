@@ -0,0 +1,109 @@
+package jwtest
+
+import "time"
+
+// Week is a Monday with a midweek meeting in the September 2026 workbook and a study
+// article in the July 2026 Watchtower
+var Week = time.Date(2026, 9, 7, 0, 0, 0, 0, time.UTC)
+
+const workbookWeek = `<html><body>
+<header><h1 data-pid="1">SEPTEMBER 7-13</h1><h2 data-pid="2">PROVERBS 1</h2></header>
+<h3 data-pid="3">Song 76 and Prayer | Opening Comments (1 min.)</h3>
+<h2 data-pid="4">TREASURES FROM GOD’S WORD</h2>
+<h3 data-pid="5">1. Listen to Wise Counsel</h3>
+<p data-pid="6">(10 min.) Discussion.</p>
+<h2 data-pid="10">LIVING AS CHRISTIANS</h2>
+<h3 data-pid="11">Song 77</h3>
+<h3 data-pid="12">2. Local Needs (15 min.)</h3>
+<h3 data-pid="20">Concluding Comments (3 min.) | Song 78 and Prayer</h3>
+</body></html>`
+
+// NewSampleCDN serves a workbook and a Watchtower for Week, the th brochure the workbook
+// refers to, and their songs and videos; Close it when done
+func NewSampleCDN() (*CDN, error) {
+	cdn := NewCDN("E")
+
+	mwb := Publication{
+		Symbol: "mwb", Language: "E", Year: 2026, IssueTagNumber: 20260900,
+		Documents: []Document{
+			{ID: 1, MepsDocumentID: 202026321, Class: 106, Title: "September 7-13", Content: workbookWeek},
+			{ID: 2, MepsDocumentID: 202026322, Class: 106, Title: "September 14-20"},
+		},
+		DatedTexts: []DatedText{
+			{ID: 1, DocumentID: 1, FirstDate: 20260907},
+			{ID: 2, DocumentID: 2, FirstDate: 20260914},
+		},
+		Multimedia: []Multimedia{
+			{ID: 1, MimeType: "video/mp4", Track: 76, KeySymbol: "sjjm"},
+			{ID: 2, MimeType: "image/jpeg", FilePath: "mwb_E_202609_01.jpg", Caption: "A father counsels his son"},
+			{ID: 3, MimeType: "video/mp4", Track: 1, MepsDocumentID: 502026100},
+			{ID: 4, MimeType: "video/mp4", Track: 77, KeySymbol: "sjjm"},
+			{ID: 5, MimeType: "video/mp4", Track: 2, KeySymbol: "mwbv", IssueTagNumber: 20260900},
+			{ID: 6, MimeType: "video/mp4", Track: 78, KeySymbol: "sjjm"},
+			{ID: 7, MimeType: "video/mp4", Track: 1, KeySymbol: "sjjm"},
+		},
+		DocumentMultimedia: []DocumentMultimedia{
+			{ID: 1, DocumentID: 1, MultimediaID: 1, BeginParagraph: 3},
+			{ID: 2, DocumentID: 1, MultimediaID: 2, BeginParagraph: 6},
+			{ID: 3, DocumentID: 1, MultimediaID: 3, BeginParagraph: 6},
+			{ID: 4, DocumentID: 1, MultimediaID: 4, BeginParagraph: 11},
+			{ID: 5, DocumentID: 1, MultimediaID: 5, BeginParagraph: 12},
+			{ID: 6, DocumentID: 1, MultimediaID: 6, BeginParagraph: 20},
+			// the next week
+			{ID: 7, DocumentID: 2, MultimediaID: 7, BeginParagraph: 3},
+		},
+		Extracts:         []Extract{{ID: 1, RefMepsDocumentID: 1102023302, RefPublicationID: 1}},
+		DocumentExtracts: []DocumentExtract{{DocumentID: 1, ExtractID: 1}},
+		RefPublications:  []RefPublication{{ID: 1, UndatedSymbol: "th"}},
+		Files:            map[string][]byte{"mwb_E_202609_01.jpg": []byte("workbook picture")},
+	}
+
+	w := Publication{
+		Symbol: "w", Language: "E", Year: 2026, IssueTagNumber: 20260700,
+		Documents: []Document{
+			{ID: 1, MepsDocumentID: 2026401, Class: 13, Title: "Contents"},
+			{ID: 2, MepsDocumentID: 2026402, Class: 40, Title: "Study Article 27"},
+		},
+		// the songs of a study article are the multimedia its dated text begins and ends with
+		DatedTexts: []DatedText{{ID: 1, DocumentID: 2, FirstDate: 20260907, BeginParagraph: 1, EndParagraph: 2}},
+		Multimedia: []Multimedia{
+			{ID: 1, MimeType: "video/mp4", Track: 12, KeySymbol: "sjjm"},
+			{ID: 2, MimeType: "video/mp4", Track: 34, KeySymbol: "sjjm"},
+			{ID: 3, MimeType: "image/jpeg", FilePath: "w_E_202607_01.jpg", Caption: "Brothers preaching"},
+		},
+		DocumentMultimedia: []DocumentMultimedia{{ID: 1, DocumentID: 2, MultimediaID: 3, BeginParagraph: 4}},
+		Files:              map[string][]byte{"w_E_202607_01.jpg": []byte("study picture")},
+	}
+
+	th := Publication{
+		Symbol: "th", Language: "E", Year: 2018,
+		Documents: []Document{{ID: 1, MepsDocumentID: 1102023302, Class: 68, Title: "Study 2"}},
+		Multimedia: []Multimedia{
+			{ID: 1, MimeType: "image/jpeg", FilePath: "1102023302_univ_lsr_lg.jpg"},
+			// left out by the default exclusions
+			{ID: 2, MimeType: "image/jpeg", FilePath: "1102018440_univ_cnt_01.jpg"},
+		},
+		DocumentMultimedia: []DocumentMultimedia{
+			{ID: 1, DocumentID: 1, MultimediaID: 1, BeginParagraph: 1},
+			{ID: 2, DocumentID: 1, MultimediaID: 2, BeginParagraph: 2},
+		},
+		Files: map[string][]byte{
+			"1102023302_univ_lsr_lg.jpg": []byte("th picture"),
+			"1102018440_univ_cnt_01.jpg": []byte("th counsel point"),
+		},
+	}
+
+	for _, p := range []Publication{mwb, w, th} {
+		if err := cdn.AddJWPUB(p); err != nil {
+			cdn.Close()
+			return nil, err
+		}
+	}
+	for track, title := range map[int]string{12: "Song Twelve", 34: "Song Thirty-Four",
+		76: "How Does It Make You Feel?", 77: "Light in a Darkened World", 78: "Teaching the Word of God"} {
+		cdn.AddSong(track, title, []byte("song "+title))
+	}
+	cdn.AddDocVideo(502026100, 1, "Listen to Wise Counsel", []byte("document video"))
+	cdn.AddPubVideo("mwbv", 20260900, 2, "Local Needs", []byte("workbook video"))
+	return cdn, nil
+}
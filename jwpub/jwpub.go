@@ -0,0 +1,183 @@
+// Package jwpub reads JWPUB files: the documents, songs, pictures and videos of the meeting
+// publications, and the program of the midweek meeting.
+package jwpub
+
+import (
+	"archive/zip"
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const (
+	dbDriver   = "sqlite3"
+	tempDBFile = "pub.db"
+)
+
+// Publication is the database of a JWPUB file, and the contents it came from for the pictures
+type Publication struct {
+	DB       *sql.DB
+	contents []byte
+	tempDir  string
+}
+
+// Document is a document of a publication, with the week it is for
+type Document struct {
+	ID   int
+	Date time.Time
+}
+
+// Origin records which publication and document a picture or video was found in
+type Origin struct {
+	PubSymbol      string
+	DocumentMepsID int64
+}
+
+// Video is a video a publication shows. It is found on the CDN by its document and track,
+// or by its publication, issue and track.
+type Video struct {
+	Name           string // file name, once it is known
+	IssueTagNumber int
+	MepsDocumentID sql.NullInt64
+	Track          sql.NullInt64
+	KeySymbol      sql.NullString
+	Origin
+}
+
+// MediaID tells videos apart, whatever language or resolution they are fetched in
+func (v Video) MediaID() string {
+	if v.IssueTagNumber == 0 && v.MepsDocumentID.Valid {
+		return fmt.Sprintf("doc/%d/%d", v.MepsDocumentID.Int64, v.Track.Int64)
+	}
+	return fmt.Sprintf("pub/%s/%d/%d", v.KeySymbol.String, v.IssueTagNumber, v.Track.Int64)
+}
+
+// Picture is an image in the contents of a publication; Payload is only read by the meeting queries
+type Picture struct {
+	Name    string
+	Caption string
+	Payload []byte
+	Origin
+}
+
+// Media is any multimedia of a document; Name is the file path of pictures
+type Media struct {
+	Video
+	MimeType string
+}
+
+// LinkedDocument is a document of another publication that a document refers to
+type LinkedDocument struct {
+	PublicationSymbol string
+	MepsDocumentID    int64
+}
+
+// Open opens the database matching dbPattern, like mwb*.db, in a JWPUB file
+func Open(jwpubBytes []byte, dbPattern string) (p *Publication, err error) {
+	contents, err := unzipFile(jwpubBytes, "contents")
+	if err != nil {
+		return
+	}
+
+	dbBytes, err := unzipFile(contents, dbPattern)
+	if err != nil {
+		return
+	}
+
+	tempDir, err := os.MkdirTemp("", "jwpub_fetcher_")
+	if err != nil {
+		return
+	}
+
+	// write this file to disk; quick check doesn't show an easy way for sqlite to handle things in memory only
+	dbFilename := filepath.Join(tempDir, tempDBFile)
+	err = os.WriteFile(dbFilename, dbBytes, 0644)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return
+	}
+
+	sqlDB, err := sql.Open(dbDriver, dbFilename)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return
+	}
+
+	return &Publication{DB: sqlDB, contents: contents, tempDir: tempDir}, nil
+}
+
+func (p *Publication) Close() error {
+	err := p.DB.Close()
+	os.RemoveAll(p.tempDir)
+	return err
+}
+
+// File reads a file from the contents of the publication, like a picture
+func (p *Publication) File(name string) ([]byte, error) {
+	return unzipFile(p.contents, name)
+}
+
+// Day is the date of t at midnight UTC, the way publications store dates
+func Day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// IssueOf returns the month of the issue of pub with the meeting of the week of date
+func IssueOf(pub string, date time.Time) time.Time {
+	month := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	switch pub {
+	case "w":
+		// W is published 2 months prior to it beeing needed for the meeting
+		return month.AddDate(0, -2, 0)
+	case "mwb":
+		// mwb is released two months at a time.
+		if month.Month()%2 == 0 {
+			return month.AddDate(0, -1, 0)
+		}
+	}
+	return month
+}
+
+// FilePattern matches the file names jw.org gives the issue of pub in lang published in the month
+// of issue, eg. mwb_E_202609.jwpub; undated publications are named like th_E.jwpub
+func FilePattern(pub, lang string, issue time.Time) string {
+	switch pub {
+	case "w", "mwb":
+		return fmt.Sprintf("%s_%s_%d%02d*.jwpub", pub, lang, issue.Year(), issue.Month())
+	}
+	return fmt.Sprintf("%s_%s*.jwpub", pub, lang)
+}
+
+// unzipFile will decompress a zip archive, and return the bytes of the first file matching the pattern
+// pattern is matched using filepath.Match
+func unzipFile(zipBytes []byte, pattern string) ([]byte, error) {
+	reader := bytes.NewReader(zipBytes)
+	r, err := zip.NewReader(reader, int64(len(zipBytes)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		if match, _ := filepath.Match(pattern, f.Name); !match {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		return b, err
+	}
+
+	return nil, errors.New("no files matched the pattern")
+}
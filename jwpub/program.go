@@ -0,0 +1,340 @@
+package jwpub
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// contentMask is mixed into the hash of the publication card to get the key and IV of document contents
+const contentMask = "11cbb5587e32846d4c26790c633da289f66fe5842a3a585ce1bc3a294af5ada7"
+
+// songMinutes is about how long a song with its introduction takes; the workbook doesn't time them
+const songMinutes = 5
+
+// durationPattern finds the time of a part, like (10 min.) or (10 Min.)
+var durationPattern = regexp.MustCompile(`\((\d+)\s*\p{L}{1,6}\.?\)`)
+
+// Program is the program of a midweek meeting as printed in the workbook
+type Program struct {
+	Week  time.Time
+	Title string // like SEPTEMBER 1-7 | PROVERBS 1
+	Parts []Part
+}
+
+type Part struct {
+	Section  string `json:",omitempty"`
+	Title    string
+	Minutes  int      `json:",omitempty"`
+	Songs    []string `json:",omitempty"`
+	Pictures []string `json:",omitempty"` // file names in the workbook
+	Videos   []string `json:",omitempty"` // see Video.MediaID
+
+	doc   int
+	first int // paragraph the part starts at
+	last  int // last paragraph of the part, 0 for the last part of a document
+	text  string
+}
+
+// ParagraphMedia is shown at a paragraph of a document
+type ParagraphMedia struct {
+	DocumentID int
+	Paragraph  int
+	Media
+}
+
+// Program reads the program from the contents of the workbook documents of a week,
+// and finds the songs and media of each part by the paragraphs they are shown at
+func (pub *Publication) Program(docs []Document) (p Program, err error) {
+	if len(docs) == 0 {
+		return p, errors.New("no documents for the week")
+	}
+	p.Week = docs[0].Date
+
+	card, err := pub.Card()
+	if err != nil {
+		return
+	}
+
+	for _, doc := range docs {
+		content, err := pub.DocumentContent(doc.ID)
+		if err != nil {
+			return p, err
+		}
+		if len(content) == 0 {
+			continue
+		}
+
+		page, err := DecryptContent(card, content)
+		if err != nil {
+			return p, fmt.Errorf("unable to decode document %d: %v", doc.ID, err)
+		}
+		title, parts, err := ParseProgram(page)
+		if err != nil {
+			return p, fmt.Errorf("unable to read document %d: %v", doc.ID, err)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		if p.Title == "" {
+			p.Title = title
+		}
+		for _, part := range parts {
+			part.doc = doc.ID
+			p.Parts = append(p.Parts, part)
+		}
+	}
+	if len(p.Parts) == 0 {
+		return p, errors.New("no program parts in the documents of the week")
+	}
+
+	media, err := pub.ParagraphMedia(docs)
+	if err != nil {
+		return
+	}
+	p.attach(media)
+
+	logrus.Debug("Program()", p)
+	return
+}
+
+// DecryptContent decodes the content of a document: it is deflated and then encrypted
+// with a key and IV derived from the publication card
+func DecryptContent(card string, content []byte) ([]byte, error) {
+	mask, err := hex.DecodeString(contentMask)
+	if err != nil {
+		return nil, err
+	}
+	secret := sha256.Sum256([]byte(card))
+	for i := range secret {
+		secret[i] ^= mask[i]
+	}
+
+	if len(content) == 0 || len(content)%aes.BlockSize != 0 {
+		return nil, errors.New("content is not made of whole blocks")
+	}
+	block, err := aes.NewCipher(secret[:16])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(content))
+	cipher.NewCBCDecrypter(block, secret[16:]).CryptBlocks(plain, content)
+
+	// PKCS#7 padding
+	pad := int(plain[len(plain)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errors.New("content doesn't decrypt with the key of the publication")
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(plain[:len(plain)-pad]))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+// ParseProgram finds the parts of a workbook page: every h3 starts a part, which belongs to the section
+// of the h2 before it. The headings in the header of the page make its title.
+func ParseProgram(page []byte) (title string, parts []Part, err error) {
+	root, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return
+	}
+
+	var headings []string
+	var section string
+	paragraph := 0
+
+	var walk func(n *html.Node, inHeader bool)
+	walk = func(n *html.Node, inHeader bool) {
+		switch n.Type {
+		case html.ElementNode:
+			for _, a := range n.Attr {
+				if a.Key != "data-pid" {
+					continue
+				}
+				if pid, err := strconv.Atoi(a.Val); err == nil {
+					paragraph = pid
+				}
+			}
+
+			switch n.DataAtom {
+			case atom.Header:
+				inHeader = true
+			case atom.H1:
+				headings = append(headings, nodeText(n))
+				return
+			case atom.H2:
+				if inHeader {
+					headings = append(headings, nodeText(n))
+				} else {
+					section = nodeText(n)
+				}
+				return
+			case atom.H3:
+				parts = append(parts, Part{Section: section, Title: nodeText(n), first: paragraph})
+				return
+			}
+		case html.TextNode:
+			if len(parts) > 0 {
+				parts[len(parts)-1].text += n.Data + " "
+			}
+		}
+
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child, inHeader)
+		}
+	}
+	walk(root, false)
+
+	for i := range parts {
+		part := &parts[i]
+		if i+1 < len(parts) {
+			part.last = parts[i+1].first - 1
+		}
+
+		// the time is in the heading of older workbooks, and in the first paragraph of newer ones
+		if m := durationPattern.FindStringSubmatch(part.Title); m != nil {
+			part.Minutes, _ = strconv.Atoi(m[1])
+			part.Title = strings.Join(strings.Fields(strings.Replace(part.Title, m[0], "", 1)), " ")
+		} else if m := durationPattern.FindStringSubmatch(part.text); m != nil {
+			part.Minutes, _ = strconv.Atoi(m[1])
+		}
+		part.text = ""
+	}
+
+	return strings.Join(headings, " | "), parts, nil
+}
+
+// nodeText is the text in n, with its white space collapsed
+func nodeText(n *html.Node) string {
+	var text []string
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text = append(text, n.Data)
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			collect(child)
+		}
+	}
+	collect(n)
+	return strings.Join(strings.Fields(strings.Join(text, "")), " ")
+}
+
+// attach adds the media to the parts they are shown in
+func (p *Program) attach(media []ParagraphMedia) {
+	for _, m := range media {
+		for i := range p.Parts {
+			part := &p.Parts[i]
+			if part.doc != m.DocumentID || m.Paragraph < part.first || (part.last != 0 && m.Paragraph > part.last) {
+				continue
+			}
+
+			switch {
+			case m.KeySymbol.String == "sjjm" && m.Track.Valid:
+				part.Songs = append(part.Songs, strconv.FormatInt(m.Track.Int64, 10))
+			case strings.HasPrefix(m.MimeType, "image/") && m.Name != "":
+				part.Pictures = append(part.Pictures, m.Name)
+			case m.MimeType == "video/mp4":
+				part.Videos = append(part.Videos, m.Video.MediaID())
+			}
+			break
+		}
+	}
+}
+
+// SongPart, PicturePart and VideoPart name the part that shows a song, a picture or a video,
+// or are empty when no part does. A nil program has no parts.
+func (p *Program) SongPart(num string) string {
+	return p.partOf(num, func(part Part) []string { return part.Songs })
+}
+
+func (p *Program) PicturePart(name string) string {
+	return p.partOf(name, func(part Part) []string { return part.Pictures })
+}
+
+// VideoPart finds the video by its Video.MediaID
+func (p *Program) VideoPart(id string) string {
+	return p.partOf(id, func(part Part) []string { return part.Videos })
+}
+
+// PictureIndex is the index of the part that shows a picture, or the number of parts when none does
+func (p *Program) PictureIndex(name string) int {
+	return p.partIndex(name, func(part Part) []string { return part.Pictures })
+}
+
+func (p *Program) partOf(id string, ids func(Part) []string) string {
+	if i := p.partIndex(id, ids); p != nil && i < len(p.Parts) {
+		return p.Parts[i].Title
+	}
+	return ""
+}
+
+func (p *Program) partIndex(id string, ids func(Part) []string) int {
+	if p == nil {
+		return 0
+	}
+	for n, part := range p.Parts {
+		for _, i := range ids(part) {
+			if i == id {
+				return n
+			}
+		}
+	}
+	return len(p.Parts)
+}
+
+// WriteRunSheet prints the parts with the time each starts at when the meeting starts at start.
+// Songs are counted as songMinutes, since the workbook doesn't time them.
+func (p Program) WriteRunSheet(w io.Writer, start time.Time) error {
+	fmt.Fprintf(w, "Midweek meeting, week of %s\n", p.Week.Format("Mon 2 Jan 2006"))
+	fmt.Fprintf(w, "%s\n\n", p.Title)
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	at := start
+	section := ""
+	for _, part := range p.Parts {
+		if part.Section != section {
+			section = part.Section
+			fmt.Fprintf(tw, "\t%s\t\t\n", section)
+		}
+
+		minutes := ""
+		if part.Minutes > 0 {
+			minutes = fmt.Sprintf("%d min", part.Minutes)
+		}
+		var media []string
+		if len(part.Songs) > 0 {
+			media = append(media, "song "+strings.Join(part.Songs, ", "))
+		}
+		if len(part.Pictures) > 0 {
+			media = append(media, "pictures "+strings.Join(part.Pictures, ", "))
+		}
+		if len(part.Videos) > 0 {
+			media = append(media, "videos "+strings.Join(part.Videos, ", "))
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", at.Format("15:04"), part.Title, minutes, strings.Join(media, "; "))
+		at = at.Add(time.Duration(part.Minutes+songMinutes*len(part.Songs)) * time.Minute)
+	}
+	fmt.Fprintf(tw, "%s\tEnd\t\t\n", at.Format("15:04"))
+	return tw.Flush()
+}
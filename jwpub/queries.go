@@ -0,0 +1,577 @@
+package jwpub
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const jwpubDateFormat = "20060102"
+
+// DocumentMedia returns the multimedia of the document with MEPS id mepsID
+func (p *Publication) DocumentMedia(mepsID int64) (media []Media, err error) {
+	// get all docIDs
+	sqlQuery := fmt.Sprintf(`SELECT Multimedia.MimeType,
+																	Multimedia.FilePath,
+																	Multimedia.Track,
+																	Multimedia.KeySymbol,
+																	Multimedia.MepsDocumentId,
+																	Multimedia.IssueTagNumber
+													 FROM Document
+													 INNER JOIN DocumentMultimedia
+													 ON Document.DocumentId = DocumentMultimedia.DocumentId
+													 INNER JOIN Multimedia
+													 ON DocumentMultimedia.MultimediaId = Multimedia.MultimediaId
+													 WHERE Document.MepsDocumentId = %d`, mepsID)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get allDocs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m Media
+		err = rows.Scan(
+			&m.MimeType,
+			&m.Name,
+			&m.Track,
+			&m.KeySymbol,
+			&m.MepsDocumentID,
+			&m.IssueTagNumber,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan allDocs row: %v", err)
+		}
+		media = append(media, m)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after allDocs query: %v", err)
+	}
+
+	return
+}
+
+// MWBDocuments returns all documents of a workbook, each with the week of the dated text it comes after
+func (p *Publication) MWBDocuments() (mwbDocuments []Document, err error) {
+	// get all docIDs
+	allDocs := make([]Document, 0)
+	sqlQuery := `SELECT DocumentId AS LastDocId
+	             FROM Document
+	             ORDER BY DocumentId ASC`
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get allDocs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mepsDoc Document
+		err = rows.Scan(
+			&mepsDoc.ID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan allDocs row: %v", err)
+		}
+		allDocs = append(allDocs, mepsDoc)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after allDocs query: %v", err)
+	}
+
+	// Get primary docIDs w/ dates
+	primaryDocs := make([]Document, 0)
+	sqlQuery = `SELECT DocumentId, CAST(FirstDateOffset AS TEXT)
+	            FROM DatedText`
+
+	rows, err = p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get documents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var mepsDoc Document
+		var date string
+		err = rows.Scan(
+			&mepsDoc.ID,
+			&date,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan document row: %v", err)
+		}
+
+		mepsDoc.Date, err = time.Parse(jwpubDateFormat, date)
+		if err != nil {
+			return
+		}
+		primaryDocs = append(primaryDocs, mepsDoc)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after document query: %v", err)
+	}
+
+	// glue it all together
+	var docDate time.Time
+	for _, doc := range allDocs {
+		for _, pdoc := range primaryDocs {
+			if pdoc.ID == doc.ID {
+				docDate = pdoc.Date
+			}
+		}
+		doc.Date = docDate
+		mwbDocuments = append(mwbDocuments, doc)
+	}
+
+	return
+}
+
+// WTDocuments returns the study articles of a Watchtower
+func (p *Publication) WTDocuments() (wtDocumentIDs []int, err error) {
+	wtDocumentIDs = make([]int, 0)
+	sqlQuery := `SELECT DocumentId
+							 FROM Document
+							 WHERE Class=40
+							 ORDER BY DocumentId ASC`
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get documents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var docID int
+		err = rows.Scan(
+			&docID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan document row: %v", err)
+		}
+		wtDocumentIDs = append(wtDocumentIDs, docID)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after document query: %v", err)
+	}
+
+	return
+}
+
+// WTDates returns the weeks of the study articles of a Watchtower, in the order of WTDocuments
+func (p *Publication) WTDates() (wtDates []time.Time, err error) {
+	wtDates = make([]time.Time, 0)
+	sqlQuery := `SELECT  CAST(FirstDateOffset AS TEXT) AS Date
+							 FROM DatedText
+							 ORDER BY DatedTextId ASC`
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get dates: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		err = rows.Scan(
+			&date,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan date row: %v", err)
+		}
+
+		wdate, err := time.Parse(jwpubDateFormat, date)
+		if err != nil {
+			return nil, err
+		}
+		wtDates = append(wtDates, wdate)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after date query: %v", err)
+	}
+
+	return
+}
+
+// MWBSongs returns the songs of workbook documents
+func (p *Publication) MWBSongs(docIDs []Document) (songs []string, err error) {
+	var whereDID string
+	for i, did := range docIDs {
+		if i != 0 {
+			whereDID += " OR "
+		}
+		whereDID += fmt.Sprintf("DocumentId=%v", did.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT Track
+													 FROM DocumentMultimedia
+													 INNER JOIN Multimedia
+													 ON DocumentMultimedia.MultimediaId = Multimedia.MultimediaId
+													 WHERE (%s)
+													 AND BeginParagraphOrdinal IS NOT NULL
+													 AND KeySymbol='sjjm'
+													 ORDER BY DocumentMultimediaId ASC;`, whereDID)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get songs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var track string
+		err = rows.Scan(
+			&track,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan song row: %v", err)
+		}
+		songs = append(songs, track)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after song query: %v", err)
+	}
+
+	logrus.Debug("MWBSongs()", songs)
+	return
+}
+
+// MWBVideos returns the videos of workbook documents
+func (p *Publication) MWBVideos(docIDs []Document) (videos []Video, err error) {
+	var whereDID string
+	for i, did := range docIDs {
+		if i != 0 {
+			whereDID += " OR "
+		}
+		whereDID += fmt.Sprintf("DocumentMultimedia.DocumentId=%v", did.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT Multimedia.Track,
+																	Multimedia.KeySymbol,
+																	Multimedia.MepsDocumentId,
+																	Multimedia.IssueTagNumber,
+																	Document.MepsDocumentId
+													 FROM DocumentMultimedia
+													 INNER JOIN Multimedia
+													 ON DocumentMultimedia.MultimediaId = Multimedia.MultimediaId
+													 INNER JOIN Document
+													 ON DocumentMultimedia.DocumentId = Document.DocumentId
+													 WHERE (%s)
+													 AND Multimedia.MimeType="video/mp4"
+ 													 AND ( Multimedia.MepsDocumentId IS NOT NULL OR Multimedia.IssueTagNumber != 0)
+													 ORDER BY DocumentMultimedia.DocumentMultimediaId ASC;`, whereDID)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get videos: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v Video
+		err = rows.Scan(
+			&v.Track,
+			&v.KeySymbol,
+			&v.MepsDocumentID,
+			&v.IssueTagNumber,
+			&v.DocumentMepsID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan video row: %v", err)
+		}
+		v.PubSymbol = "mwb"
+		videos = append(videos, v)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after song query: %v", err)
+	}
+
+	logrus.Debug("MWBVideos()", videos)
+	return
+}
+
+// WTSongs returns the songs of the study article of the week of date
+func (p *Publication) WTSongs(date time.Time) (songs []string, err error) {
+	d := date.Format(jwpubDateFormat)
+	sqlQuery := fmt.Sprintf(`SELECT Multimedia.Track
+							 FROM DatedText
+							 INNER JOIN Multimedia
+							 ON DatedText.BeginParagraphOrdinal = Multimedia.MultimediaId
+							 WHERE FirstDateOffset=%s
+							 AND KeySymbol='sjjm'
+							 UNION ALL
+							 SELECT Multimedia.Track
+							 FROM DatedText
+							 INNER JOIN Multimedia
+							 ON DatedText.EndParagraphOrdinal = Multimedia.MultimediaId
+							 WHERE FirstDateOffset=%s
+							 AND KeySymbol='sjjm';
+`, d, d)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get wtsongs: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var track string
+		err = rows.Scan(
+			&track,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan wtsong row: %v", err)
+		}
+		songs = append(songs, track)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after wtsong query: %v", err)
+	}
+
+	logrus.Debug("WTSongs()", songs)
+	return
+}
+
+// Images returns the pictures of documents, without their payload
+func (p *Publication) Images(docIDs []Document) (pictures []Picture, err error) {
+	var whereDID string
+	for i, did := range docIDs {
+		if i != 0 {
+			whereDID += " OR "
+		}
+		whereDID += fmt.Sprintf("DocumentMultimedia.DocumentId=%v", did.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT Multimedia.FilePath, IFNULL(Multimedia.Caption, ''), Document.MepsDocumentId
+													 FROM DocumentMultimedia
+													 INNER JOIN Multimedia
+													 ON DocumentMultimedia.MultimediaId = Multimedia.MultimediaId
+													 INNER JOIN Document
+													 ON DocumentMultimedia.DocumentId = Document.DocumentId
+													 WHERE (%s)
+													 AND DocumentMultimedia.BeginParagraphOrdinal IS NOT NULL
+													 AND Multimedia.FilePath!=''
+													 ORDER BY DocumentMultimedia.DocumentMultimediaId ASC;`, whereDID)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get documents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pic Picture
+		err = rows.Scan(
+			&pic.Name,
+			&pic.Caption,
+			&pic.DocumentMepsID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan document row: %v", err)
+		}
+		pictures = append(pictures, pic)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after document query: %v", err)
+	}
+
+	logrus.Debug("Images()", pictures)
+	return
+}
+
+// LinkedDocuments returns the documents of the publications pubSymbols that documents refer to
+func (p *Publication) LinkedDocuments(docIDs []Document, pubSymbols []string) (docs []LinkedDocument, err error) {
+	if len(pubSymbols) == 0 {
+		return
+	}
+
+	var whereDID string
+	for i, did := range docIDs {
+		if i != 0 {
+			whereDID += " OR "
+		}
+		whereDID += fmt.Sprintf("DocumentExtract.DocumentId=%v", did.ID)
+	}
+
+	var refSymbol string
+	for i, pubSymbol := range pubSymbols {
+		if i != 0 {
+			refSymbol += " OR "
+		}
+		refSymbol += fmt.Sprintf("RefPublication.UndatedSymbol == '%s'", pubSymbol)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT RefPublication.UndatedSymbol, Extract.RefMepsDocumentId
+													 FROM DocumentExtract
+													 INNER JOIN Extract
+													 ON DocumentExtract.ExtractId = Extract.ExtractId
+													 INNER JOIN  RefPublication
+													 ON Extract.RefPublicationId = RefPublication.RefPublicationId
+													 WHERE (%s)
+													 AND (%s);`,
+		whereDID, refSymbol)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get lined documents: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ld LinkedDocument
+		err = rows.Scan(
+			&ld.PublicationSymbol,
+			&ld.MepsDocumentID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan lined document row: %v", err)
+		}
+		docs = append(docs, ld)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after lined document query: %v", err)
+	}
+
+	logrus.Debug("LinkedDocuments()", docs)
+	return
+}
+
+// Card returns what the key of the document contents is derived from,
+// like 0_mwb_2026_20260900; undated publications leave out the issue
+func (p *Publication) Card() (card string, err error) {
+	sqlQuery := `SELECT MepsLanguageIndex, Symbol, Year, IssueTagNumber
+							 FROM Publication
+							 LIMIT 1`
+
+	var lang, year, issue int
+	var symbol string
+	err = p.DB.QueryRow(sqlQuery).Scan(&lang, &symbol, &year, &issue)
+	if err != nil {
+		return "", fmt.Errorf("unable to get publication: %v", err)
+	}
+
+	card = fmt.Sprintf("%d_%s_%d", lang, symbol, year)
+	if issue != 0 {
+		card += fmt.Sprintf("_%d", issue)
+	}
+	return
+}
+
+// DocumentContent returns the encrypted content of a document; see DecryptContent
+func (p *Publication) DocumentContent(docID int) (content []byte, err error) {
+	err = p.DB.QueryRow(`SELECT Content FROM Document WHERE DocumentId=?`, docID).Scan(&content)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get content of document %d: %v", docID, err)
+	}
+	return
+}
+
+// ParagraphMedia returns the media of the documents with the paragraph they are shown at
+func (p *Publication) ParagraphMedia(docIDs []Document) (media []ParagraphMedia, err error) {
+	var whereDID string
+	for i, did := range docIDs {
+		if i != 0 {
+			whereDID += " OR "
+		}
+		whereDID += fmt.Sprintf("DocumentMultimedia.DocumentId=%v", did.ID)
+	}
+
+	sqlQuery := fmt.Sprintf(`SELECT DocumentMultimedia.DocumentId,
+																	DocumentMultimedia.BeginParagraphOrdinal,
+																	IFNULL(Multimedia.MimeType, ''),
+																	IFNULL(Multimedia.FilePath, ''),
+																	Multimedia.Track,
+																	Multimedia.KeySymbol,
+																	Multimedia.MepsDocumentId,
+																	Multimedia.IssueTagNumber
+													 FROM DocumentMultimedia
+													 INNER JOIN Multimedia
+													 ON DocumentMultimedia.MultimediaId = Multimedia.MultimediaId
+													 WHERE (%s)
+													 AND DocumentMultimedia.BeginParagraphOrdinal IS NOT NULL
+													 ORDER BY DocumentMultimedia.DocumentMultimediaId ASC;`, whereDID)
+
+	rows, err := p.DB.Query(sqlQuery)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get paragraph media: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m ParagraphMedia
+		err = rows.Scan(
+			&m.DocumentID,
+			&m.Paragraph,
+			&m.MimeType,
+			&m.Name,
+			&m.Track,
+			&m.KeySymbol,
+			&m.MepsDocumentID,
+			&m.IssueTagNumber,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("unable to scan paragraph media row: %v", err)
+		}
+		media = append(media, m)
+	}
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("row error after paragraph media query: %v", err)
+	}
+
+	logrus.Debug("ParagraphMedia()", media)
+	return
+}
+
+// MWBWeek returns the documents of a workbook for the week of date; only the day of date counts
+func (p *Publication) MWBWeek(date time.Time) (docGroups []Document, err error) {
+	date = Day(date)
+	docs, err := p.MWBDocuments()
+	if err != nil {
+		return
+	}
+	logrus.Debug("docs >>", docs)
+
+	for _, doc := range docs {
+		if !date.Equal(doc.Date) {
+			continue
+		}
+		docGroups = append(docGroups, doc)
+	}
+	return
+}
+
+// WTStudy returns the document of the study article for the week of date, or 0 when there is none;
+// only the day of date counts
+func (p *Publication) WTStudy(date time.Time) (doc int, err error) {
+	date = Day(date)
+	docs, err := p.WTDocuments()
+	if err != nil {
+		return
+	}
+	logrus.Debug("docs >>", docs)
+
+	dates, err := p.WTDates()
+	if err != nil {
+		return
+	}
+	logrus.Debug("dates >>", dates)
+
+	for i, d := range docs {
+		if i < len(dates) && date.Equal(dates[i]) {
+			return d, nil
+		}
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package jwpub
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Table is the contents of a table of the database of a publication
+type Table struct {
+	Name    string
+	Columns []string        `json:",omitempty"`
+	Rows    [][]interface{} `json:",omitempty"`
+	Error   string          `json:",omitempty"`
+}
+
+// Table reads all of a table; a table that can't be read has an Error instead of rows
+func (p *Publication) Table(name string) (t Table) {
+	t.Name = name
+	rows, err := p.DB.Query(`SELECT * FROM "` + strings.ReplaceAll(name, `"`, `""`) + `"`)
+	if err != nil {
+		t.Error = err.Error()
+		return
+	}
+	defer rows.Close()
+
+	t.Columns, err = rows.Columns()
+	if err != nil {
+		t.Error = err.Error()
+		return
+	}
+
+	for rows.Next() {
+		values := make([]interface{}, len(t.Columns))
+		pointers := make([]interface{}, len(values))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			t.Error = err.Error()
+			return
+		}
+		for i, v := range values {
+			// blobs, like the encrypted content of documents, are only shown by their size
+			if b, ok := v.([]byte); ok {
+				values[i] = fmt.Sprintf("<%d bytes>", len(b))
+			}
+		}
+		t.Rows = append(t.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		t.Error = err.Error()
+	}
+	return
+}
@@ -1,51 +1,121 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/meeting"
+	"meeting-media/playlist"
 )
 
 const (
-	RES240      = "240p"
-	RES360      = "360p"
-	RES480      = "480p"
-	RES720      = "720p"
+	RES240      = cdn.Res240
+	RES360      = cdn.Res360
+	RES480      = cdn.Res480
+	RES720      = cdn.Res720
+	AUDIO       = cdn.Audio // mp3 for songs; videos use the lowest resolution
+	SYMLINK     = playlist.Symlink
+	HARDLINK    = playlist.Hardlink
+	COPY        = playlist.Copy
+	APP_NAME    = "meeting-media"
 	CONFIG_FILE = ".meeting-media"
-	WM          = "WM"
-	MM          = "MM"
+	WM          = meeting.WM
+	MM          = meeting.MM
 )
 
 func main() {
-	config := NewConfig()
+	debugMode := flag.Bool("d", false, "fake downloading; print debug info")
+	configPath := flag.String("config", "", "path to the config file")
+	profile := flag.String("profile", "", "profile to use instead of the selected one")
+	progressFormat := flag.String("progress", "terminal", "how commands report progress (terminal or json)")
+	flag.Parse()
+
+	config, err := NewConfig(*configPath, *profile)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	config.DebugMode = debugMode
 	a := app.New()
 
-	config.DebugMode = flag.Bool("d", false, "fake downloading; print debug info")
-	flag.Parse()
 	if *config.DebugMode {
 		logrus.SetLevel(logrus.DebugLevel)
 		logrus.Debug("RUNNING IN DEBUG MODE")
 	}
 
-	progressBar := widget.NewProgressBar()
-	config.Progress = &progress{0, "", progressBar}
-	pbFormatter := func() string { return config.Progress.Title }
-	config.Progress.ProgressBar.TextFormatter = pbFormatter
+	if flag.NArg() > 0 {
+		switch *progressFormat {
+		case "json":
+			config.Progress = cache.NewProgress(cache.NewJSONReporter(os.Stdout))
+		default:
+			config.Progress = cache.NewProgress(cache.TerminalReporter{W: os.Stderr})
+		}
+
+		// the first ^C stops the downloads cleanly, a second one kills the program
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		err := config.runCommand(ctx, flag.Args())
+		stop()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	gp := newGUIProgress()
+	config.Progress = cache.NewProgress(gp)
+	logrus.AddHook(gp.log)
+	job := newGUIJob()
 
-	settingsTab := container.NewTabItem("", config.settingsGUI())
-	settingsTab.Icon = theme.SettingsIcon()
-	tabs := container.NewAppTabs(
-		container.NewTabItem("Midweek", config.mGUI(MM)),
-		container.NewTabItem("Weekend", config.mGUI(WM)),
-		settingsTab,
-	)
+	daemonStatus := widget.NewLabel(config.daemonStatusText())
+	go func() {
+		for range time.Tick(time.Minute) {
+			daemonStatus.SetText(config.daemonStatusText())
+		}
+	}()
 
 	w := a.NewWindow("Meeting Downloader")
-	w.SetContent(container.NewVBox(tabs))
+	w.Resize(fyne.NewSize(600, 700))
+
+	// content is rebuilt when switching profiles, since every widget shows the settings of the old one
+	var content func(showSettings bool) fyne.CanvasObject
+	content = func(showSettings bool) fyne.CanvasObject {
+		job.forget()
+		settingsTab := container.NewTabItem("", config.settingsGUI(w, func() {
+			w.SetContent(content(true))
+		}, job))
+		settingsTab.Icon = theme.SettingsIcon()
+		tabs := container.NewAppTabs(
+			container.NewTabItem("Midweek", config.mGUI(w, MM, job)),
+			container.NewTabItem("Weekend", config.mGUI(w, WM, job)),
+			settingsTab,
+		)
+		if showSettings {
+			tabs.SelectTab(settingsTab)
+		}
+
+		if config.Profile == "" {
+			w.SetTitle("Meeting Downloader")
+		} else {
+			w.SetTitle("Meeting Downloader - " + config.Profile)
+		}
+
+		// progress and log are shared by both meetings, below the tabs
+		status := container.NewVBox(tabs, gp.item, gp.total, job.cancelButton, daemonStatus)
+		return container.NewBorder(status, nil, nil, nil, gp.log.scroll)
+	}
+	w.SetContent(content(false))
 
 	w.ShowAndRun()
 }
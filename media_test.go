@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"meeting-media/cache"
+	"meeting-media/internal/jwtest"
+	"meeting-media/playlist"
+)
+
+// newTestCDN serves the sample publications, songs and videos
+func newTestCDN(t *testing.T) *jwtest.CDN {
+	t.Helper()
+	cdn, err := jwtest.NewSampleCDN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(cdn.Close)
+	return cdn
+}
+
+// newTestConfig is a configuration that fetches from cdn into temporary folders
+func newTestConfig(t *testing.T, cdn *jwtest.CDN) *Config {
+	t.Helper()
+	c := &Config{}
+	c.LoadDefaults()
+	dir := t.TempDir()
+	c.SaveLocation = filepath.Join(dir, "meetings")
+	c.CacheLocation = filepath.Join(dir, "cache")
+	c.PubSymbols = []string{"th"}
+	c.Retries = 0
+	c.RequestsPerMinute = 0
+	if err := c.applyNetworkSettings(); err != nil {
+		t.Fatal(err)
+	}
+	c.HttpClient.HTTPClient.Transport = cdn.Transport()
+
+	debug := false
+	c.DebugMode = &debug
+	c.Progress = cache.NewProgress(cache.TerminalReporter{W: io.Discard})
+	c.Date = jwtest.Week
+	return c
+}
+
+func TestFetchMeetingStuff(t *testing.T) {
+	cdn := newTestCDN(t)
+	c := newTestConfig(t, cdn)
+
+	if err := c.fetchMeetingStuff(context.Background(), MM); err != nil {
+		t.Fatal(err)
+	}
+
+	m3u, err := os.ReadFile(filepath.Join(c.SaveLocation, playlist.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"#EXTINF:-1,Song 76 and Prayer | Opening Comments: Song 76: How Does It Make You Feel?\nsjjm_E_076_r720P.mp4\n",
+		"sjjm_E_077_r720P.mp4\n",
+		"sjjm_E_078_r720P.mp4\n",
+		"doc_502026100_1_r720P.mp4\n",
+		"mwbv_E_202609_2_r720P.mp4\n",
+		"#EXTINF:-1,1. Listen to Wise Counsel: A father counsels his son\nmwb_E_202609_01.jpg\n",
+		"1102023302_univ_lsr_lg.jpg\n",
+	} {
+		if !strings.Contains(string(m3u), want) {
+			t.Errorf("playlist doesn't have %q:\n%s", want, m3u)
+		}
+	}
+
+	song, err := os.ReadFile(filepath.Join(c.SaveLocation, "sjjm_E_077_r720P.mp4"))
+	if err != nil || string(song) != "song Light in a Darkened World" {
+		t.Errorf("saved song 77 is %q: %v", song, err)
+	}
+	if title := c.cdnClient().CachedSongTitle("77"); title != "Light in a Darkened World" {
+		t.Errorf("cached title of song 77 is %q", title)
+	}
+
+	// a second fetch only checks the media info, which is still fresh, so nothing is requested
+	requests := len(cdn.Requests())
+	if err := c.fetchMeetingStuff(context.Background(), MM); err != nil {
+		t.Fatal(err)
+	}
+	if again := cdn.Requests()[requests:]; len(again) > 0 {
+		t.Errorf("fetching again requested %v", again)
+	}
+}
+
+func TestFetchMeetingStuffOffline(t *testing.T) {
+	cdn := newTestCDN(t)
+	c := newTestConfig(t, cdn)
+
+	if _, err := c.gatherMedia(context.Background(), MM); err != nil {
+		t.Fatal(err)
+	}
+
+	cdn.Close()
+	c.Offline = true
+	items, err := c.gatherMedia(context.Background(), MM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, it := range items {
+		names = append(names, it.Name)
+	}
+	want := []string{"sjjm_E_076_r720P.mp4", "sjjm_E_077_r720P.mp4", "sjjm_E_078_r720P.mp4",
+		"doc_502026100_1_r720P.mp4", "mwbv_E_202609_2_r720P.mp4", "mwb_E_202609_01.jpg", "1102023302_univ_lsr_lg.jpg"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("offline media %v, want %v", names, want)
+	}
+}
+
+func TestFetchMeetingStuffDryRun(t *testing.T) {
+	c := newTestConfig(t, newTestCDN(t))
+	*c.DebugMode = true
+	if err := c.fetchMeetingStuff(context.Background(), MM); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the publications were downloaded
+	index, err := cache.ReadIndex(c.CacheLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name := range index {
+		if !strings.HasSuffix(name, ".jwpub") {
+			t.Errorf("%s is in the cache index", name)
+		}
+	}
+	if entries, err := os.ReadDir(c.SaveLocation); err == nil && len(entries) > 0 {
+		t.Errorf("%d files saved", len(entries))
+	}
+	if results, err := c.verify(context.Background(), false); err != nil || len(results) > 0 {
+		t.Errorf("verify after a dry run: %v %v", results, err)
+	}
+}
@@ -0,0 +1,196 @@
+package meeting
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/jwpub"
+)
+
+// Exclusion is a rule for pictures and videos that should never be fetched.
+// Every field that is set has to match; Filename is matched using filepath.Match.
+type Exclusion struct {
+	Filename       string
+	PubSymbol      string
+	MimeType       string
+	MepsDocumentID int64
+}
+
+// Excluded is a picture or video that was left out, with the rule that matched it
+type Excluded struct {
+	Name string
+	Rule Exclusion
+}
+
+// MediaRef is everything an exclusion rule can be matched against
+type MediaRef struct {
+	Name            string
+	MimeType        string
+	PubSymbols      []string
+	MepsDocumentIDs []int64
+}
+
+// Matches reports whether m is excluded by e; a rule without fields matches nothing
+func (e Exclusion) Matches(m MediaRef) bool {
+	if e == (Exclusion{}) {
+		return false
+	}
+
+	if e.Filename != "" {
+		if match, _ := filepath.Match(e.Filename, m.Name); !match {
+			return false
+		}
+	}
+
+	if e.MimeType != "" && !strings.EqualFold(e.MimeType, m.MimeType) {
+		return false
+	}
+
+	if e.PubSymbol != "" {
+		found := false
+		for _, p := range m.PubSymbols {
+			if strings.EqualFold(e.PubSymbol, p) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if e.MepsDocumentID != 0 {
+		found := false
+		for _, id := range m.MepsDocumentIDs {
+			if e.MepsDocumentID == id {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e Exclusion) String() string {
+	var rules []string
+	if e.Filename != "" {
+		rules = append(rules, "filename "+e.Filename)
+	}
+	if e.PubSymbol != "" {
+		rules = append(rules, "pub "+e.PubSymbol)
+	}
+	if e.MimeType != "" {
+		rules = append(rules, "type "+e.MimeType)
+	}
+	if e.MepsDocumentID != 0 {
+		rules = append(rules, fmt.Sprintf("document %d", e.MepsDocumentID))
+	}
+	return strings.Join(rules, ", ")
+}
+
+// ParseExclusion reads a rule in the form String writes it, eg. "pub th, filename *.jpg"
+func ParseExclusion(text string) (e Exclusion, err error) {
+	for _, part := range strings.Split(text, ",") {
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return e, fmt.Errorf("%q is not a rule; use filename, pub, type or document followed by a value", strings.TrimSpace(part))
+		}
+		switch value := fields[1]; fields[0] {
+		case "filename":
+			e.Filename = value
+		case "pub":
+			e.PubSymbol = value
+		case "type":
+			e.MimeType = value
+		case "document":
+			if e.MepsDocumentID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return e, fmt.Errorf("%q is not a document id", value)
+			}
+		default:
+			return e, fmt.Errorf("unknown rule %q", fields[0])
+		}
+	}
+	return e, e.Validate()
+}
+
+// ParseExclusions reads one rule per line, skipping empty lines
+func ParseExclusions(text string) (rules []Exclusion, err error) {
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		e, err := ParseExclusion(line)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, e)
+	}
+	return rules, nil
+}
+
+// Validate checks that e can be used
+func (e Exclusion) Validate() error {
+	if e == (Exclusion{}) {
+		return errors.New("rule matches nothing")
+	}
+	if _, err := filepath.Match(e.Filename, ""); err != nil {
+		return fmt.Errorf("bad filename pattern %q", e.Filename)
+	}
+	return nil
+}
+
+// isExcluded checks m against the exclusion rules and records the first rule that matched
+func (f *Fetcher) isExcluded(m MediaRef) bool {
+	return f.excludedAs(m, m.Name)
+}
+
+// excludedAs is isExcluded for m that may not have a name yet, as videos before they are looked up:
+// rules with a file name are left for later then, and label is what m is called in the report
+func (f *Fetcher) excludedAs(m MediaRef, label string) bool {
+	for _, e := range f.Exclusions {
+		if m.Name == "" && e.Filename != "" {
+			continue
+		}
+		if !e.Matches(m) {
+			continue
+		}
+		logrus.Infof("excluding %s (%s)", label, e)
+		f.Excluded = append(f.Excluded, Excluded{Name: label, Rule: e})
+		return true
+	}
+	return false
+}
+
+func (f *Fetcher) pictureExcluded(p jwpub.Picture) bool {
+	return f.isExcluded(MediaRef{
+		Name:            p.Name,
+		MimeType:        mime.TypeByExtension(filepath.Ext(p.Name)),
+		PubSymbols:      []string{p.PubSymbol},
+		MepsDocumentIDs: []int64{p.DocumentMepsID},
+	})
+}
+
+func (f *Fetcher) videoExcluded(v jwpub.Video) bool {
+	m := MediaRef{
+		Name:            v.Name,
+		MimeType:        "video/mp4",
+		PubSymbols:      []string{v.PubSymbol, v.KeySymbol.String},
+		MepsDocumentIDs: []int64{v.DocumentMepsID},
+	}
+	if v.MepsDocumentID.Valid {
+		m.MepsDocumentIDs = append(m.MepsDocumentIDs, v.MepsDocumentID.Int64)
+	}
+	label := v.Name
+	if label == "" {
+		label = "video " + v.MediaID()
+	}
+	return f.excludedAs(m, label)
+}
@@ -0,0 +1,349 @@
+// Package meeting finds the songs, pictures and videos of a meeting in the publications it is
+// prepared from, and downloads them into the cache in the order of the meeting.
+package meeting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/jwpub"
+)
+
+// the meetings of a week
+const (
+	MM = "MM" // midweek, from the workbook
+	WM = "WM" // weekend, from the Watchtower
+)
+
+// Fetcher gets the media of meetings. What it leaves out or can't find is added to its Report.
+type Fetcher struct {
+	CDN               *cdn.Client
+	Cache             *cache.Cache
+	Resolution        string      // one of the cdn resolutions
+	PublicationFolder string      // where to look for publications offline, besides the cache
+	PubSymbols        []string    // publications the media of linked documents are taken from
+	Exclusions        []Exclusion // pictures and videos that are never fetched
+	FetchOtherMedia   bool        // pictures and videos, besides the songs
+	AutoFetch         bool        // take the songs and media from the publications
+
+	Report
+}
+
+// Data is what the publications have for the meeting of a week
+type Data struct {
+	DateString string
+	Songs      []string
+	Pictures   []jwpub.Picture
+	Videos     []jwpub.Video
+	Program    *jwpub.Program // only for the midweek meeting
+}
+
+// MidweekData reads the workbook for the week of week
+func (f *Fetcher) MidweekData(ctx context.Context, week time.Time) (mmd Data, err error) {
+	jwpubBytes, err := f.JWPub(ctx, "mwb", jwpub.IssueOf("mwb", week))
+	if err != nil {
+		return
+	}
+
+	pub, err := jwpub.Open(jwpubBytes, "mwb*.db")
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+
+	docGroups, err := pub.MWBWeek(week)
+	if err != nil {
+		return
+	}
+
+	if len(docGroups) == 0 {
+		return mmd, errors.New("no docs found!")
+	}
+
+	songs, err := pub.MWBSongs(docGroups)
+	if err != nil {
+		return
+	}
+	mmd = Data{
+		DateString: docGroups[0].Date.Format("2006-01-02"),
+		Songs:      songs,
+	}
+
+	// the program only labels the media, so the media are fetched without it
+	if program, err := pub.Program(docGroups); err != nil {
+		logrus.Warnf("unable to read the program of the week: %v", err)
+	} else {
+		mmd.Program = &program
+	}
+
+	if f.FetchOtherMedia {
+		images, err := pub.Images(docGroups)
+		if err != nil {
+			return Data{}, err
+		}
+
+		seen := make(map[string]bool)
+		for _, image := range images {
+			// skip images we already have; copies from other documents are merged by Gather
+			if seen[image.Name] {
+				continue
+			}
+			seen[image.Name] = true
+
+			image.PubSymbol = "mwb"
+			if f.pictureExcluded(image) {
+				continue
+			}
+
+			// fetch image from contents
+			image.Payload, err = pub.File(image.Name)
+			if err != nil {
+				return Data{}, errors.New("problem getting pic")
+			}
+
+			// queue for storage
+			mmd.Pictures = append(mmd.Pictures, image)
+		}
+
+		mmd.Videos, err = pub.MWBVideos(docGroups)
+		if err != nil {
+			return Data{}, err
+		}
+
+		linkedDocs, err := pub.LinkedDocuments(docGroups, f.PubSymbols)
+		if err != nil {
+			return Data{}, err
+		}
+		for _, ld := range linkedDocs {
+			docMedia, err := f.DocumentMedia(ctx, ld)
+			if ctx.Err() != nil {
+				return Data{}, err
+			}
+			if err != nil {
+				logrus.Warn(err)
+			}
+			mmd.Pictures = append(mmd.Pictures, docMedia.Pictures...)
+			mmd.Videos = append(mmd.Videos, docMedia.Videos...)
+		}
+	}
+
+	return
+}
+
+// WeekendData reads the Watchtower for the week of week
+func (f *Fetcher) WeekendData(ctx context.Context, week time.Time) (wmd Data, err error) {
+	jwpubBytes, err := f.JWPub(ctx, "w", jwpub.IssueOf("w", week))
+	if err != nil {
+		return
+	}
+
+	pub, err := jwpub.Open(jwpubBytes, "w*.db")
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+
+	doc, err := pub.WTStudy(week)
+	if err != nil {
+		return
+	}
+	if doc == 0 {
+		return wmd, fmt.Errorf("no study for the week of %s", week.Format("2006-01-02"))
+	}
+
+	songs, err := pub.WTSongs(week)
+	if err != nil {
+		return
+	}
+	wmd = Data{
+		DateString: week.Format("2006-01-02"),
+		Songs:      songs,
+	}
+
+	if f.FetchOtherMedia {
+		pics := []jwpub.Picture{}
+		images, err := pub.Images([]jwpub.Document{{ID: doc}})
+		if err != nil {
+			return wmd, err
+		}
+		for _, image := range images {
+			image.PubSymbol = "w"
+			if f.pictureExcluded(image) {
+				continue
+			}
+
+			image.Payload, err = pub.File(image.Name)
+			if err != nil {
+				return wmd, errors.New("problem getting pic")
+			}
+
+			pics = append(pics, image)
+		}
+		wmd.Pictures = pics
+	}
+
+	return
+}
+
+// DocumentMedia reads the pictures and videos of a document another publication links to
+func (f *Fetcher) DocumentMedia(ctx context.Context, ld jwpub.LinkedDocument) (md Data, err error) {
+	jwpubBytes, err := f.JWPub(ctx, ld.PublicationSymbol, time.Time{})
+	if err != nil {
+		return
+	}
+
+	pub, err := jwpub.Open(jwpubBytes, ld.PublicationSymbol+"*.db")
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+
+	mepsDocs, err := pub.DocumentMedia(ld.MepsDocumentID)
+	if err != nil {
+		return
+	}
+	logrus.Debug("docs >>", mepsDocs)
+
+	for _, d := range mepsDocs {
+		d.Origin = jwpub.Origin{PubSymbol: ld.PublicationSymbol, DocumentMepsID: ld.MepsDocumentID}
+
+		switch d.MimeType {
+		case "image/jpeg":
+			pic := jwpub.Picture{Name: d.Name, Origin: d.Origin}
+			if f.pictureExcluded(pic) {
+				continue
+			}
+
+			pic.Payload, err = pub.File(d.Name)
+			if err != nil {
+				return Data{}, errors.New("problem getting pic")
+			}
+			md.Pictures = append(md.Pictures, pic)
+		case "video/mp4":
+			md.Videos = append(md.Videos, d.Video)
+		}
+	}
+
+	return
+}
+
+// Program gets the program of the midweek meeting of the week of week
+func (f *Fetcher) Program(ctx context.Context, week time.Time) (p jwpub.Program, err error) {
+	jwpubBytes, err := f.JWPub(ctx, "mwb", jwpub.IssueOf("mwb", week))
+	if err != nil {
+		return
+	}
+
+	pub, err := jwpub.Open(jwpubBytes, "mwb*.db")
+	if err != nil {
+		return
+	}
+	defer pub.Close()
+
+	docs, err := pub.MWBWeek(week)
+	if err != nil {
+		return
+	}
+	return pub.Program(docs)
+}
+
+// IssueCached reports whether the issue of pub for the week of week is in the cache already
+func (f *Fetcher) IssueCached(pub string, week time.Time) bool {
+	pattern := jwpub.FilePattern(pub, f.CDN.Language, jwpub.IssueOf(pub, week))
+	matches, _ := filepath.Glob(filepath.Join(f.Cache.Dir, pattern))
+	return len(matches) > 0
+}
+
+// JWPub gets the issue of pub published in the month of issue, from the cache if it can.
+// The issue is ignored for undated publications.
+// Every publication is an item of the fetch's progress.
+func (f *Fetcher) JWPub(ctx context.Context, pub string, issue time.Time) ([]byte, error) {
+	f.Cache.Progress.Expect(1)
+	if f.CDN.Offline {
+		return f.localJWPub(pub, issue)
+	}
+
+	m, err := f.CDN.JWPubInfo(ctx, pub, issue.Year(), int(issue.Month()))
+	if err != nil {
+		f.Cache.Progress.Fail(pub, err)
+		return nil, err
+	}
+
+	if len(m.Files[f.CDN.Language].JWPUB) == 0 {
+		err = errors.New("no JWPUB available for " + pub)
+		f.Cache.Progress.Fail(pub, err)
+		return nil, err
+	}
+	jwpubItem := m.Files[f.CDN.Language].JWPUB[0]
+	filename := filepath.Base(jwpubItem.File.URL)
+	payload, err := f.Cache.Get(filename, jwpubItem.File.Checksum)
+	if err == nil {
+		f.Cache.Progress.Finish(filename)
+		return payload, err
+	}
+
+	payload, err = f.download(ctx, jwpubItem)
+	if err == nil {
+		f.Cache.Put(cache.File{
+			Name:     filename,
+			Payload:  payload,
+			URL:      jwpubItem.File.URL,
+			Checksum: jwpubItem.File.Checksum,
+		})
+	}
+
+	return payload, err
+}
+
+// localJWPub finds the issue of pub in the cache or in PublicationFolder
+func (f *Fetcher) localJWPub(pub string, issue time.Time) ([]byte, error) {
+	pattern := jwpub.FilePattern(pub, f.CDN.Language, issue)
+	for _, dir := range []string{f.Cache.Dir, f.PublicationFolder} {
+		if dir == "" {
+			continue
+		}
+		matches, _ := filepath.Glob(filepath.Join(dir, pattern))
+		if len(matches) == 0 {
+			continue
+		}
+		sort.Strings(matches)
+		name := matches[len(matches)-1]
+		logrus.Infof("using %s", name)
+		payload, err := os.ReadFile(name)
+		if err != nil {
+			f.Cache.Progress.Fail(filepath.Base(name), err)
+		} else {
+			f.Cache.Progress.Finish(filepath.Base(name))
+		}
+		return payload, err
+	}
+
+	err := fmt.Errorf("not in the cache or %s", f.PublicationFolder)
+	if f.PublicationFolder == "" {
+		err = cache.ErrNotCached
+	}
+	f.Cache.Progress.Fail(pattern, err)
+	return nil, f.missing(pattern, err)
+}
+
+func (f *Fetcher) download(ctx context.Context, jwpi cdn.JWPubItem) (body []byte, err error) {
+	name := filepath.Base(jwpi.File.URL)
+	defer func() {
+		if err != nil {
+			f.Cache.Progress.Fail(name, err)
+		} else {
+			f.Cache.Progress.Finish(name)
+		}
+	}()
+
+	return f.Cache.Download(ctx, jwpi.File.URL, int64(jwpi.Filesize), jwpi.File.Checksum)
+}
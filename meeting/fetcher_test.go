@@ -0,0 +1,264 @@
+package meeting
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/internal/jwtest"
+	"meeting-media/jwpub"
+	"meeting-media/playlist"
+)
+
+// newTestFetcher is a fetcher that gets the sample publications from a fake CDN into a temporary cache
+func newTestFetcher(t *testing.T) (*Fetcher, *jwtest.CDN) {
+	t.Helper()
+	server, err := jwtest.NewSampleCDN()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	client, err := cdn.NewHTTPClient(cdn.Settings{ConnectTimeout: "5s", RetryWaitMin: "1s", RetryWaitMax: "1s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient.Transport = server.Transport()
+
+	dir := filepath.Join(t.TempDir(), "cache")
+	c := &cdn.Client{HTTP: client, Language: "E", CacheDir: dir}
+	return &Fetcher{
+		CDN:             c,
+		Cache:           &cache.Cache{Dir: dir, CDN: c, Progress: cache.NewProgress(cache.TerminalReporter{W: io.Discard})},
+		Resolution:      cdn.Res720,
+		PubSymbols:      []string{"th"},
+		Exclusions:      []Exclusion{{PubSymbol: "th", Filename: "1102018440_univ_cnt_*.jpg"}},
+		FetchOtherMedia: true,
+		AutoFetch:       true,
+	}, server
+}
+
+func pictureNames(pictures []jwpub.Picture) (names []string) {
+	for _, p := range pictures {
+		names = append(names, p.Name)
+	}
+	return
+}
+
+func TestMidweekData(t *testing.T) {
+	f, _ := newTestFetcher(t)
+
+	mmd, err := f.MidweekData(context.Background(), jwtest.Week)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mmd.DateString != "2026-09-07" {
+		t.Errorf("date %q", mmd.DateString)
+	}
+	if want := []string{"76", "77", "78"}; !reflect.DeepEqual(mmd.Songs, want) {
+		t.Errorf("songs %v, want %v", mmd.Songs, want)
+	}
+	if want := []string{"mwb_E_202609_01.jpg", "1102023302_univ_lsr_lg.jpg"}; !reflect.DeepEqual(pictureNames(mmd.Pictures), want) {
+		t.Errorf("pictures %v, want %v", pictureNames(mmd.Pictures), want)
+	}
+	if string(mmd.Pictures[0].Payload) != "workbook picture" || mmd.Pictures[0].Caption != "A father counsels his son" {
+		t.Errorf("workbook picture %q with caption %q", mmd.Pictures[0].Payload, mmd.Pictures[0].Caption)
+	}
+	if len(f.Excluded) != 1 || f.Excluded[0].Name != "1102018440_univ_cnt_01.jpg" {
+		t.Errorf("excluded %v", f.Excluded)
+	}
+
+	var videos []string
+	for _, v := range mmd.Videos {
+		videos = append(videos, v.MediaID())
+	}
+	if want := []string{"doc/502026100/1", "pub/mwbv/20260900/2"}; !reflect.DeepEqual(videos, want) {
+		t.Errorf("videos %v, want %v", videos, want)
+	}
+
+	if mmd.Program == nil {
+		t.Fatal("no program")
+	}
+	if mmd.Program.Title != "SEPTEMBER 7-13 | PROVERBS 1" {
+		t.Errorf("program title %q", mmd.Program.Title)
+	}
+	if part := mmd.Program.VideoPart("pub/mwbv/20260900/2"); part != "2. Local Needs" {
+		t.Errorf("workbook video in part %q", part)
+	}
+	if part := mmd.Program.PicturePart("mwb_E_202609_01.jpg"); part != "1. Listen to Wise Counsel" {
+		t.Errorf("workbook picture in part %q", part)
+	}
+	if minutes := mmd.Program.Parts[1].Minutes; minutes != 10 {
+		t.Errorf("first treasures part takes %d minutes", minutes)
+	}
+}
+
+func TestWeekendData(t *testing.T) {
+	f, _ := newTestFetcher(t)
+
+	wmd, err := f.WeekendData(context.Background(), jwtest.Week)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"12", "34"}; !reflect.DeepEqual(wmd.Songs, want) {
+		t.Errorf("songs %v, want %v", wmd.Songs, want)
+	}
+	if want := []string{"w_E_202607_01.jpg"}; !reflect.DeepEqual(pictureNames(wmd.Pictures), want) {
+		t.Errorf("pictures %v, want %v", pictureNames(wmd.Pictures), want)
+	}
+	if string(wmd.Pictures[0].Payload) != "study picture" {
+		t.Errorf("study picture %q", wmd.Pictures[0].Payload)
+	}
+}
+
+func TestGather(t *testing.T) {
+	f, _ := newTestFetcher(t)
+
+	items, songs, err := f.Gather(context.Background(), MM, jwtest.Week, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"76", "77", "78"}; !reflect.DeepEqual(songs, want) {
+		t.Errorf("songs %v, want %v", songs, want)
+	}
+
+	var names []string
+	for _, it := range items {
+		names = append(names, it.Name)
+	}
+	want := []string{"sjjm_E_076_r720P.mp4", "sjjm_E_077_r720P.mp4", "sjjm_E_078_r720P.mp4",
+		"doc_502026100_1_r720P.mp4", "mwbv_E_202609_2_r720P.mp4", "mwb_E_202609_01.jpg", "1102023302_univ_lsr_lg.jpg"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("media %v, want %v", names, want)
+	}
+	if items[0].Kind != playlist.Song || items[0].Title != "Song 76: How Does It Make You Feel?" {
+		t.Errorf("first item is %v %q", items[0].Kind, items[0].Title)
+	}
+	if items[0].Part != "Song 76 and Prayer | Opening Comments" {
+		t.Errorf("first song in part %q", items[0].Part)
+	}
+}
+
+// the week as a clock would give it: Monday at 1am east of UTC, which is still Sunday in UTC
+var localWeek = time.Date(2026, 9, 7, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+func TestGatherLocalTime(t *testing.T) {
+	f, _ := newTestFetcher(t)
+
+	items, songs, err := f.Gather(context.Background(), MM, localWeek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"76", "77", "78"}; !reflect.DeepEqual(songs, want) {
+		t.Errorf("midweek songs %v, want %v", songs, want)
+	}
+	if len(items) != 7 {
+		t.Errorf("%d midweek items", len(items))
+	}
+
+	_, songs, err = f.Gather(context.Background(), WM, localWeek, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"", "12", "34"}; !reflect.DeepEqual(songs, want) {
+		t.Errorf("weekend songs %q, want %q", songs, want)
+	}
+}
+
+func TestGatherNoStudy(t *testing.T) {
+	f, _ := newTestFetcher(t)
+
+	// the sample Watchtower only has a study for jwtest.Week
+	_, _, err := f.Gather(context.Background(), WM, jwtest.Week.AddDate(0, 0, 7), []string{"", "", ""})
+	if err == nil || !strings.Contains(err.Error(), "no study for the week of 2026-09-14") {
+		t.Errorf("weekend without a study: %v", err)
+	}
+}
+
+type recorder []cache.Event
+
+func (r *recorder) Report(e cache.Event) {
+	*r = append(*r, e)
+}
+
+func TestGatherProgress(t *testing.T) {
+	f, _ := newTestFetcher(t)
+	var events recorder
+	f.Cache.Progress = cache.NewProgress(&events)
+
+	if _, _, err := f.Gather(context.Background(), MM, jwtest.Week, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// the workbook and th, 3 songs, 2 videos and 2 pictures
+	last := events[len(events)-1]
+	if last.Done != 9 || last.Total != 9 {
+		t.Errorf("fetch ended at %d of %d", last.Done, last.Total)
+	}
+	if first := events[0]; first.Item != "mwb_E_202609.jwpub" {
+		t.Errorf("first item %q", first.Item)
+	}
+	for _, e := range events {
+		if e.TotalFraction() > 1 {
+			t.Errorf("%s %s at %d of %d", e.Event, e.Item, e.Done, e.Total)
+		}
+	}
+}
+
+func TestVideoRenditions(t *testing.T) {
+	f, server := newTestFetcher(t)
+	ctx := context.Background()
+
+	// the best there is when 720p isn't
+	server.Resolutions = []string{"240p", "360p"}
+	server.AddDocVideo(502026200, 1, "Fewer Renditions", []byte("small video"))
+	v := &jwpub.Video{MepsDocumentID: sql.NullInt64{Int64: 502026200, Valid: true}, Track: sql.NullInt64{Int64: 1, Valid: true}}
+	if it, err := f.Video(ctx, v); err != nil || it.Name != "doc_502026200_1_r360P.mp4" {
+		t.Errorf("video %q: %v", it.Name, err)
+	}
+
+	// none at all
+	server.Resolutions = nil
+	server.AddDocVideo(502026300, 1, "No Renditions", nil)
+	server.AddPubVideo("mwbv", 20260900, 3, "No Renditions", nil)
+	for _, v := range []*jwpub.Video{
+		{MepsDocumentID: sql.NullInt64{Int64: 502026300, Valid: true}, Track: sql.NullInt64{Int64: 1, Valid: true}},
+		{IssueTagNumber: 20260900, KeySymbol: sql.NullString{String: "mwbv", Valid: true}, Track: sql.NullInt64{Int64: 3, Valid: true}},
+	} {
+		if _, err := f.Video(ctx, v); err == nil || !strings.Contains(err.Error(), "no media available") {
+			t.Errorf("%s: %v", v.MediaID(), err)
+		}
+	}
+}
+
+func TestVideoExcludedBeforeLookup(t *testing.T) {
+	f, server := newTestFetcher(t)
+	f.Exclusions = []Exclusion{{MepsDocumentID: 502026100}}
+	v := &jwpub.Video{MepsDocumentID: sql.NullInt64{Int64: 502026100, Valid: true}, Track: sql.NullInt64{Int64: 1, Valid: true}}
+
+	if _, err := f.Video(context.Background(), v); err != errExcluded {
+		t.Fatalf("excluded video: %v", err)
+	}
+	if requests := server.Requests(); len(requests) > 0 {
+		t.Errorf("excluded video was looked up: %v", requests)
+	}
+	if want := []Excluded{{Name: "video doc/502026100/1", Rule: f.Exclusions[0]}}; !reflect.DeepEqual(f.Excluded, want) {
+		t.Errorf("excluded %v, want %v", f.Excluded, want)
+	}
+
+	// offline it isn't reported missing either
+	f.CDN.Offline = true
+	f.Excluded = nil
+	if _, err := f.Video(context.Background(), v); err != errExcluded || len(f.Missing) > 0 {
+		t.Errorf("excluded video offline: %v, missing %v", err, f.Missing)
+	}
+}
@@ -0,0 +1,342 @@
+package meeting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+	"meeting-media/cdn"
+	"meeting-media/jwpub"
+	"meeting-media/playlist"
+)
+
+var errExcluded = errors.New("excluded by rule")
+
+// Gather downloads everything for meeting m of the week of week into the cache, and lists it
+// in playlist order. songs are the numbers to fetch, "" where the publication has them; with
+// AutoFetch they are filled in, and returned.
+// It stops as soon as ctx is done; what was downloaded so far is kept in the cache.
+func (f *Fetcher) Gather(ctx context.Context, m string, week time.Time, songs []string) (items []playlist.Item, _ []string, err error) {
+	logrus.Debug("Gather()")
+	// the publications are counted as they are looked up, the media once they are known
+	f.Cache.Progress.Reset(0)
+
+	// the parts of the program the media are for, when it is known
+	var program *jwpub.Program
+	var pictures []jwpub.Picture
+	var videos []jwpub.Video
+	if f.AutoFetch {
+		logrus.Info("Auto-Fetching!")
+		var data Data
+		switch m {
+		case WM:
+			if data, err = f.WeekendData(ctx, week); err != nil {
+				return nil, songs, err
+			}
+			if len(data.Songs) < 2 {
+				return nil, songs, fmt.Errorf("the study for the week of %s has %d songs, not 2", week.Format("2006-01-02"), len(data.Songs))
+			}
+			if len(songs) < 1 {
+				// the opening song is chosen by the speaker
+				songs = []string{""}
+			}
+			songs = []string{
+				songs[0],
+				data.Songs[0],
+				data.Songs[1],
+			}
+		case MM:
+			if data, err = f.MidweekData(ctx, week); err != nil {
+				return nil, songs, err
+			}
+			songs = data.Songs
+			videos = data.Videos
+			program = data.Program
+		}
+
+		pictures = data.Pictures
+	}
+
+	total := 0
+	for _, song := range songs {
+		if song != "" {
+			total++
+		}
+	}
+	if f.FetchOtherMedia {
+		total += len(videos) + len(pictures)
+	}
+	f.Cache.Progress.Expect(total)
+
+	for _, song := range songs {
+		if song == "" {
+			continue
+		}
+		item, err := f.Song(ctx, song)
+		if f.CDN.Offline && ctx.Err() == nil && err != nil {
+			// reported as missing; the rest may still be there
+			continue
+		}
+		if err != nil {
+			return nil, songs, err
+		}
+		item.Part = program.SongPart(song)
+		items = append(items, item)
+	}
+
+	if f.FetchOtherMedia {
+		for _, video := range videos {
+			item, err := f.Video(ctx, &video)
+			if err == errExcluded {
+				continue
+			}
+			if ctx.Err() != nil {
+				return nil, songs, ctx.Err()
+			}
+			if err != nil {
+				logrus.Warnf("error fetching video: %s => %s", item.Name, err)
+				continue
+			}
+			item.Part = program.VideoPart(video.MediaID())
+			items = append(items, item)
+		}
+
+		// in the order of the program, so Dedupe keeps the copy that is shown first; pictures of
+		// linked publications, which no part shows, stay after those of the meeting's own publication
+		sort.SliceStable(pictures, func(i, j int) bool {
+			return program.PictureIndex(pictures[i].Name) < program.PictureIndex(pictures[j].Name)
+		})
+		for _, picture := range pictures {
+			items = append(items, playlist.Item{
+				Kind:    playlist.Picture,
+				Name:    picture.Name,
+				Title:   picture.Caption,
+				Part:    program.PicturePart(picture.Name),
+				Include: true,
+				Source:  cache.File{Name: picture.Name, Payload: picture.Payload},
+			})
+			// pictures come out of the publications, which are downloaded already
+			f.Cache.Progress.Finish(picture.Name)
+		}
+	}
+
+	items, merged := playlist.Dedupe(items)
+	f.Merged = append(f.Merged, merged...)
+	return items, songs, nil
+}
+
+// resolution is the index of the file in f.Resolution in the lists of GETPUBMEDIALINKS
+func (f *Fetcher) resolution() int {
+	switch f.Resolution {
+	case cdn.Res240:
+		return 0
+	case cdn.Res360:
+		return 1
+	case cdn.Res480:
+		return 2
+	case cdn.Res720:
+		return 3
+	}
+	return 0
+}
+
+// songFormat is what songs are fetched as, mp4 or mp3
+func (f *Fetcher) songFormat() string {
+	if f.Resolution == cdn.Audio {
+		return "mp3"
+	}
+	return "mp4"
+}
+
+// Song puts song num into the cache
+func (f *Fetcher) Song(ctx context.Context, num string) (item playlist.Item, err error) {
+	label := f.SongName(num)
+	logrus.Info("downloading " + label)
+	name := label
+	defer func() {
+		if err != nil {
+			f.Cache.Progress.Fail(name, err)
+		} else {
+			f.Cache.Progress.Finish(name)
+		}
+	}()
+
+	if f.CDN.Offline {
+		file, err := f.Cache.Find(f.songKey(num), f.songFileName(num))
+		if err != nil {
+			return item, f.missing(label, err)
+		}
+		name = file.Name
+		return playlist.Item{Kind: playlist.Song, Name: file.Name, Title: label, Include: true, Source: file}, nil
+	}
+
+	res := f.resolution()
+	songInfo, err := f.CDN.SongInfo(ctx, num, f.songFormat())
+	if err != nil {
+		return
+	}
+
+	songs := songInfo.Files[f.CDN.Language].MP4
+	if f.Resolution == cdn.Audio {
+		songs = songInfo.Files[f.CDN.Language].MP3
+		res = 0
+	}
+	if len(songs) <= res {
+		return item, errors.New("no media available for song #" + num)
+	}
+	song := songs[res]
+	if song.Title != "" {
+		label = SongLabel(num, f.CDN.RememberSongTitle(num, song.Title))
+	}
+
+	filename := filepath.Base(song.File.URL)
+	name = filename
+	file := cache.File{
+		Name:     filename,
+		URL:      song.File.URL,
+		Checksum: song.File.Checksum,
+		Key:      f.songKey(num),
+	}
+	if err = f.Cache.Fetch(ctx, file, song.Filesize); err != nil {
+		return item, err
+	}
+
+	return playlist.Item{
+		Kind:      playlist.Song,
+		Name:      filename,
+		Title:     label,
+		Thumbnail: song.TrackImage.URL,
+		Include:   true,
+		Source:    file,
+	}, nil
+}
+
+// Video puts v into the cache, and sets its name
+func (f *Fetcher) Video(ctx context.Context, v *jwpub.Video) (item playlist.Item, err error) {
+	defer func() {
+		name := item.Name
+		if name == "" {
+			name = "video " + v.KeySymbol.String
+		}
+		if err != nil && err != errExcluded {
+			f.Cache.Progress.Fail(name, err)
+		} else {
+			f.Cache.Progress.Finish(name)
+		}
+	}()
+
+	// the rules that don't need the file name spare looking the video up
+	if f.videoExcluded(*v) {
+		return item, errExcluded
+	}
+
+	if f.CDN.Offline {
+		file, err := f.Cache.Find(f.videoKey(*v), "")
+		if err != nil {
+			return item, f.missing(f.videoKey(*v), err)
+		}
+		v.Name = file.Name
+		item = playlist.Item{Kind: playlist.Video, Name: file.Name, Include: true, Source: file}
+		if f.videoExcluded(*v) {
+			return item, errExcluded
+		}
+		return item, nil
+	}
+
+	res := f.resolution()
+	var url, checksum string
+	var filesize int
+	if v.IssueTagNumber == 0 {
+		vidInfo, err := f.CDN.VideoInfo(ctx, *v)
+		if err != nil {
+			return item, err
+		}
+		files := vidInfo.Files[f.CDN.Language].MP4
+		if len(files) == 0 {
+			return item, errors.New("no media available for video " + v.MediaID())
+		}
+		// the best there is when the video has fewer renditions
+		if res >= len(files) {
+			res = len(files) - 1
+		}
+		url = files[res].File.URL
+		filesize = files[res].Filesize
+		checksum = files[res].File.Checksum
+		item.Title = files[res].Title
+		item.Thumbnail = files[res].TrackImage.URL
+
+	} else {
+		vidInfo, err := f.CDN.PubVideoInfo(ctx, *v)
+		if err != nil {
+			return item, err
+		}
+
+		if len(vidInfo.Media) == 0 || len(vidInfo.Media[0].Files) == 0 {
+			return item, errors.New("no media available for video " + v.MediaID())
+		}
+		media := vidInfo.Media[0]
+		if res >= len(media.Files) {
+			res = len(media.Files) - 1
+		}
+		for i, v := range media.Files {
+			if v.Label == f.Resolution && !v.Subtitled {
+				res = i
+				break
+			}
+		}
+
+		url = media.Files[res].Progressivedownloadurl
+		filesize = media.Files[res].Filesize
+		checksum = media.Files[res].Checksum
+		item.Title = media.Title
+		item.Thumbnail = media.Thumbnail()
+	}
+
+	filename := filepath.Base(url)
+	v.Name = filename
+	item.Kind = playlist.Video
+	item.Name = filename
+	item.Include = true
+	if f.videoExcluded(*v) {
+		return item, errExcluded
+	}
+
+	logrus.Infof("downloading video: %s", filename)
+
+	item.Source = cache.File{
+		Name:     filename,
+		URL:      url,
+		Checksum: checksum,
+		Key:      f.videoKey(*v),
+	}
+	return item, f.Cache.Fetch(ctx, item.Source, filesize)
+}
+
+// songKey and videoKey identify a download in the cache index, so it can be found offline
+func (f *Fetcher) songKey(num string) string {
+	return fmt.Sprintf("song/%s/%s/%s", f.CDN.Language, num, f.Resolution)
+}
+
+func (f *Fetcher) videoKey(v jwpub.Video) string {
+	return fmt.Sprintf("video/%s/%s/%s", f.CDN.Language, v.MediaID(), f.Resolution)
+}
+
+// songFileName is the name jw.org gives song num in the current language and resolution
+func (f *Fetcher) songFileName(num string) string {
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return ""
+	}
+	if f.Resolution == cdn.Audio {
+		return fmt.Sprintf("sjjm_%s_%03d.mp3", f.CDN.Language, n)
+	}
+	return fmt.Sprintf("sjjm_%s_%03d_r%sP.mp4", f.CDN.Language, n, strings.TrimSuffix(f.Resolution, "p"))
+}
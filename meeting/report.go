@@ -0,0 +1,74 @@
+package meeting
+
+import (
+	"fmt"
+	"strings"
+
+	"meeting-media/playlist"
+)
+
+// Report is what fetches left out or could not find, to show once they are done
+type Report struct {
+	Excluded []Excluded
+	Missing  []string // what an offline fetch could not find
+	Merged   []playlist.Merged
+	Hooks    []string // hooks run around the fetch that failed, and why
+}
+
+// missing records what an offline fetch could not find
+func (r *Report) missing(what string, err error) error {
+	r.Missing = append(r.Missing, what+": "+err.Error())
+	return fmt.Errorf("%s is missing: %w", what, err)
+}
+
+// String lists everything in the report, or is empty when there is nothing to tell
+func (r Report) String() string {
+	var summaries []string
+	for _, s := range []string{r.ExcludedSummary(), r.MissingSummary(), r.MergedSummary(), r.HooksSummary()} {
+		if s != "" {
+			summaries = append(summaries, s)
+		}
+	}
+	return strings.Join(summaries, "\n")
+}
+
+// ExcludedSummary lists what was excluded, and why
+func (r Report) ExcludedSummary() string {
+	if len(r.Excluded) == 0 {
+		return ""
+	}
+
+	summary := "Excluded:"
+	for _, e := range r.Excluded {
+		summary += fmt.Sprintf("\n%s (%s)", e.Name, e.Rule)
+	}
+	return summary
+}
+
+// MissingSummary lists what an offline fetch could not find
+func (r Report) MissingSummary() string {
+	if len(r.Missing) == 0 {
+		return ""
+	}
+	return "Missing:\n" + strings.Join(r.Missing, "\n")
+}
+
+func (r Report) MergedSummary() string {
+	if len(r.Merged) == 0 {
+		return ""
+	}
+
+	summary := "Merged:"
+	for _, m := range r.Merged {
+		summary += fmt.Sprintf("\n%s (same as %s)", m.Name, m.Into)
+	}
+	return summary
+}
+
+// HooksSummary lists the hooks that failed
+func (r Report) HooksSummary() string {
+	if len(r.Hooks) == 0 {
+		return ""
+	}
+	return "Failed hooks:\n" + strings.Join(r.Hooks, "\n")
+}
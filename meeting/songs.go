@@ -0,0 +1,37 @@
+package meeting
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseSongNumber reads a song number as typed, like 12 or #12
+func ParseSongNumber(text string) (string, error) {
+	text = strings.TrimPrefix(strings.TrimSpace(text), "#")
+	n, err := strconv.Atoi(text)
+	if err != nil || n < 1 {
+		return "", fmt.Errorf("%q is not a song number", text)
+	}
+	return strconv.Itoa(n), nil
+}
+
+// SongLabel names song num, with its title when it is known
+func SongLabel(num, title string) string {
+	if title == "" {
+		return "Song " + num
+	}
+	return fmt.Sprintf("Song %s: %s", num, title)
+}
+
+// SongName names song num with the title in the cache, without going online
+func (f *Fetcher) SongName(num string) string {
+	return SongLabel(num, f.CDN.CachedSongTitle(num))
+}
+
+// SongTitle returns the title of song num, looking it up in the format songs are fetched in
+// when it isn't cached
+func (f *Fetcher) SongTitle(ctx context.Context, num string) (string, error) {
+	return f.CDN.SongTitle(ctx, num, f.songFormat())
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"meeting-media/cdn"
+)
+
+// networkSettings are the settings the HTTP client is built from
+func (c *Config) networkSettings() cdn.Settings {
+	return cdn.Settings{
+		Proxy:             c.Proxy,
+		CACertificates:    c.CACertificates,
+		UserAgent:         c.UserAgent,
+		RequestsPerMinute: c.RequestsPerMinute,
+		ConnectTimeout:    c.ConnectTimeout,
+		Retries:           c.Retries,
+		RetryWaitMin:      c.RetryWaitMin,
+		RetryWaitMax:      c.RetryWaitMax,
+	}
+}
+
+// applyNetworkSettings replaces the HTTP client after the network settings changed;
+// the old client is kept when the new settings can't be used
+func (c *Config) applyNetworkSettings() error {
+	client, err := cdn.NewHTTPClient(c.networkSettings())
+	if err != nil {
+		return err
+	}
+	c.HttpClient = client
+	return nil
+}
+
+func validateProxy(proxy string) error {
+	if proxy == "" {
+		return nil
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("%q is not a proxy like http://proxy:8080 or socks5://proxy:1080", proxy)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("%q has no host", proxy)
+	}
+	return nil
+}
+
+func validateCertificateFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return errors.New("is a folder")
+	}
+	return nil
+}
+
+func validateDuration(text string) error {
+	d, err := time.ParseDuration(text)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration like 30s or 2m", text)
+	}
+	if d < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
+
+func validateNotNegative(n int) error {
+	if n < 0 {
+		return errors.New("must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package playlist
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Merged is a picture or video that was found more than once; only the first one is kept
+type Merged struct {
+	Name string
+	Into string // name of the item that was kept
+}
+
+// contentKey is the same for items with the same content: pictures are hashed, since they come
+// out of the publications, and videos have the checksum the API gave for them
+func contentKey(it Item) string {
+	if it.Kind == Picture {
+		sum := sha256.Sum256(it.Source.Payload)
+		return "sha256:" + hex.EncodeToString(sum[:])
+	}
+	if it.Source.Checksum != "" {
+		return "checksum:" + it.Source.Checksum
+	}
+	return "cache:" + it.Source.Name
+}
+
+// Dedupe leaves out pictures and videos that come more than once, from any publication,
+// keeping each where it comes first, and lists what it left out
+func Dedupe(items []Item) (kept []Item, merged []Merged) {
+	first := make(map[string]int)
+	for _, it := range items {
+		if it.Kind == Song {
+			kept = append(kept, it)
+			continue
+		}
+
+		key := contentKey(it)
+		i, seen := first[key]
+		if !seen {
+			first[key] = len(kept)
+			kept = append(kept, it)
+			continue
+		}
+
+		// the copy may know more about where the item is used
+		if kept[i].Title == "" {
+			kept[i].Title = it.Title
+		}
+		if kept[i].Part == "" {
+			kept[i].Part = it.Part
+		}
+		logrus.Infof("%s is the same as %s; keeping only %s", it.Name, kept[i].Name, kept[i].Name)
+		merged = append(merged, Merged{Name: it.Name, Into: kept[i].Name})
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package playlist
+
+import (
+	"reflect"
+	"testing"
+
+	"meeting-media/cache"
+)
+
+func TestDedupeLinkedPicture(t *testing.T) {
+	picture := []byte("the same picture")
+	items := []Item{
+		{Kind: Song, Name: "sjjm_E_076_r720P.mp4", Source: cache.File{Name: "sjjm_E_076_r720P.mp4"}},
+		{Kind: Video, Name: "mwbv_E_202609_2_r720P.mp4", Source: cache.File{Name: "mwbv_E_202609_2_r720P.mp4", Checksum: "abc"}},
+		// the workbook's own picture comes first in the program
+		{Kind: Picture, Name: "mwb_E_202609_01.jpg", Part: "1. Listen to Wise Counsel",
+			Source: cache.File{Name: "mwb_E_202609_01.jpg", Payload: picture}},
+		// the same picture, in the Teach Us brochure the part links to
+		{Kind: Picture, Name: "1102023302_univ_lsr_lg.jpg", Title: "A father counsels his son",
+			Source: cache.File{Name: "1102023302_univ_lsr_lg.jpg", Payload: picture}},
+		{Kind: Picture, Name: "1102023302_univ_lsr_lg_02.jpg", Source: cache.File{Name: "1102023302_univ_lsr_lg_02.jpg", Payload: []byte("another picture")}},
+	}
+
+	kept, merged := Dedupe(items)
+
+	var names []string
+	for _, it := range kept {
+		names = append(names, it.Name)
+	}
+	if want := []string{"sjjm_E_076_r720P.mp4", "mwbv_E_202609_2_r720P.mp4", "mwb_E_202609_01.jpg", "1102023302_univ_lsr_lg_02.jpg"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("kept %v, want %v", names, want)
+	}
+	if want := []Merged{{Name: "1102023302_univ_lsr_lg.jpg", Into: "mwb_E_202609_01.jpg"}}; !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged %v, want %v", merged, want)
+	}
+	if it := kept[2]; it.Part != "1. Listen to Wise Counsel" || it.Title != "A father counsels his son" {
+		t.Errorf("kept picture has part %q and title %q", it.Part, it.Title)
+	}
+}
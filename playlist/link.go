@@ -0,0 +1,150 @@
+package playlist
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+)
+
+// ways of putting the cached media into the folder they are saved to
+const (
+	Symlink  = "symlink"
+	Hardlink = "hardlink"
+	Copy     = "copy"
+)
+
+// RemoveContents deletes everything in dir but what keep accepts, if it isn't nil, and not dir itself
+func RemoveContents(dir string, keep func(name string) bool) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if keep != nil && keep(name) {
+			continue
+		}
+		err = os.RemoveAll(filepath.Join(dir, name))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Link puts the cached copy of name into s.Dir under the same name; see LinkAs
+func (s Saver) Link(name string) error {
+	return s.LinkAs(name, name)
+}
+
+// LinkAs puts the cached copy of name into s.Dir as saveName according to s.Mode,
+// falling back to a hardlink and then a copy when the filesystem does not support it.
+// A file that is already in place is left alone; anything else is replaced atomically.
+func (s Saver) LinkAs(name, saveName string) error {
+	src := filepath.Join(s.CacheDir, name)
+	dst := filepath.Join(s.Dir, saveName)
+
+	if inPlace(src, dst, s.Mode) {
+		logrus.Debugf("%s is already in place", name)
+		return nil
+	}
+
+	modes := []string{Symlink, Hardlink, Copy}
+	switch s.Mode {
+	case Hardlink:
+		modes = []string{Hardlink, Copy}
+	case Copy:
+		modes = []string{Copy}
+	}
+
+	var err error
+	for _, mode := range modes {
+		if err = replaceWith(src, dst, mode); err == nil {
+			return nil
+		}
+		logrus.Warnf("unable to %s %s: %v", mode, name, err)
+	}
+	return err
+}
+
+// inPlace reports whether dst already provides src the way mode asks for.
+// A copy with the same content is accepted in every mode, since it may be the result of a fallback.
+func inPlace(src, dst, mode string) bool {
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return false
+	}
+	dstInfo, err := os.Lstat(dst)
+	if err != nil {
+		return false
+	}
+
+	if dstInfo.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(dst)
+		return err == nil && target == src && mode != Hardlink && mode != Copy
+	}
+
+	if os.SameFile(srcInfo, dstInfo) {
+		return mode != Copy
+	}
+
+	if srcInfo.Size() != dstInfo.Size() {
+		return false
+	}
+	srcSum, err := cache.FileChecksum(src)
+	if err != nil {
+		return false
+	}
+	dstSum, err := cache.FileChecksum(dst)
+	return err == nil && srcSum == dstSum
+}
+
+func replaceWith(src, dst, mode string) (err error) {
+	tmp := dst + ".tmp"
+	os.Remove(tmp)
+
+	switch mode {
+	case Hardlink:
+		err = os.Link(src, tmp)
+	case Copy:
+		err = copyFile(src, tmp)
+	default:
+		err = os.Symlink(src, tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, dst); err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,185 @@
+// Package playlist saves fetched media into a folder, by linking or copying them from the cache,
+// and lists them in an m3u playlist in the order of the meeting.
+package playlist
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+)
+
+// File is the playlist in the folder the items are saved to
+const File = "playlist.m3u"
+
+// kinds of Item
+const (
+	Song    = "song"
+	Video   = "video"
+	Picture = "picture"
+)
+
+// Item is a fetched file as it will be saved and listed in the playlist
+type Item struct {
+	Kind      string
+	Name      string // file name in the folder it is saved to
+	Title     string
+	Part      string // title of the part of the program the item is for
+	Thumbnail string // URL of a preview image for songs and videos
+	Include   bool
+
+	Source cache.File // Payload is only kept for pictures, which are not cached
+}
+
+// Label describes the item in the playlist and the review
+func (it Item) Label() string {
+	switch {
+	case it.Part != "" && it.Title != "":
+		return it.Part + ": " + it.Title
+	case it.Part != "":
+		return it.Part
+	}
+	return it.Title
+}
+
+// Rename sets the name the item is saved under; the extension is kept
+func (it *Item) Rename(name string) error {
+	name, err := FileName(name, it.Source.Name)
+	if err != nil {
+		return err
+	}
+	it.Name = name
+	return nil
+}
+
+// FileName checks name for saving an item as, adding the extension of original when it is left out
+func FileName(name, original string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("%q is not a file name", name)
+	}
+	if ext := filepath.Ext(original); !strings.EqualFold(filepath.Ext(name), ext) {
+		name += ext
+	}
+	if name == File {
+		return "", fmt.Errorf("%s is used for the playlist", name)
+	}
+	return name, nil
+}
+
+// Check makes sure no two included items are saved under the same name
+func Check(items []Item) error {
+	seen := make(map[string]bool)
+	for _, it := range items {
+		if !it.Include {
+			continue
+		}
+		if seen[it.Name] {
+			return fmt.Errorf("%s is used for more than one item", it.Name)
+		}
+		seen[it.Name] = true
+	}
+	return nil
+}
+
+// Saver puts items into Dir, linking or copying them from the cache in CacheDir
+type Saver struct {
+	Dir      string
+	CacheDir string
+	Mode     string // Symlink, Hardlink or Copy
+	Purge    bool   // delete everything in Dir first
+	Playlist bool   // write File
+	DryRun   bool   // only log what would be saved
+
+	Keep func(name string) bool // what Purge leaves in Dir, like folders of other fetches
+}
+
+// Save puts the included items into s.Dir and writes the playlist in their order
+func (s Saver) Save(items []Item) error {
+	if err := Check(items); err != nil {
+		return err
+	}
+
+	if s.DryRun {
+		for _, it := range items {
+			if it.Include {
+				logrus.Debugf("dry run: not saving %s into %s", it.Name, s.Dir)
+			}
+		}
+		return nil
+	}
+
+	if s.Purge {
+		logrus.Info("Deleting all files in " + s.Dir)
+		if err := RemoveContents(s.Dir, s.Keep); err != nil {
+			logrus.Warn(err)
+		}
+	}
+	if err := os.MkdirAll(s.Dir, 0777); err != nil {
+		return err
+	}
+
+	var saved []Item
+	for _, it := range items {
+		if !it.Include {
+			logrus.Infof("leaving out %s", it.Name)
+			continue
+		}
+		if err := s.saveItem(it); err != nil {
+			return err
+		}
+		saved = append(saved, it)
+	}
+
+	if s.Playlist {
+		return s.writePlaylist(saved)
+	}
+	return nil
+}
+
+func (s Saver) saveItem(it Item) error {
+	saved := it.Source
+	saved.Name = it.Name
+
+	if it.Kind == Picture {
+		logrus.Infof("saving picture %s", it.Name)
+		path := filepath.Join(s.Dir, it.Name)
+		if os.WriteFile(path, it.Source.Payload, 0644) != nil {
+			return errors.New("error writing data to " + path)
+		}
+	} else {
+		if err := s.LinkAs(it.Source.Name, it.Name); err != nil {
+			return err
+		}
+		if it.Name == it.Source.Name {
+			return nil
+		}
+		// verify finds files by their cache name; record what a renamed one should contain
+		saved.Payload = nil
+	}
+
+	if err := cache.Record(s.Dir, saved); err != nil {
+		logrus.Warn(err)
+	}
+	return nil
+}
+
+func (s Saver) writePlaylist(items []Item) error {
+	logrus.Info("creating playlist")
+
+	file := filepath.Join(s.Dir, File)
+	body := "#EXTM3U\n"
+	for _, it := range items {
+		if label := it.Label(); label != "" {
+			body += "#EXTINF:-1," + label + "\n"
+		}
+		body += it.Name + "\n"
+	}
+
+	return os.WriteFile(file, []byte(body), 0644)
+}
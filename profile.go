@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+)
+
+// profileKeys are the settings every profile keeps for itself; everything else is shared
+var profileKeys = []string{
+	"AutoFetchMeetingData",
+	"FetchOtherMedia",
+	"CreatePlaylist",
+	"PurgeSaveDir",
+	"Resolution",
+	"OutputMode",
+	"SaveLocation",
+	"CacheLocation",
+	"Offline",
+	"PublicationFolder",
+	"Language",
+	"PubSymbols",
+}
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// applyProfile takes the profiles out of tree and lays the settings of the selected profile over the top level ones
+func (c *Config) applyProfile(tree *toml.Tree) {
+	if profiles, ok := tree.Get("Profiles").(*toml.Tree); ok {
+		c.profiles = profiles
+	}
+	tree.Delete("Profiles")
+
+	fileProfile, _ := tree.Get("Profile").(string)
+	if c.Profile == "" {
+		c.Profile = fileProfile
+	} else if c.Profile != fileProfile {
+		// chosen on the command line, so it is not saved as the selected profile
+		c.setFileValue("Profile", tree.Get("Profile"))
+		tree.Set("Profile", c.Profile)
+	}
+	if c.Profile == "" {
+		return
+	}
+
+	c.baseValues = make(map[string]interface{})
+	for _, key := range profileKeys {
+		c.baseValues[key] = tree.Get(key)
+	}
+
+	profile := c.profile(c.Profile)
+	if profile == nil {
+		c.loadErrors = append(c.loadErrors, fieldError{"Profile", c.unknownProfile(c.Profile)})
+		return
+	}
+
+	for _, key := range profileKeys {
+		if profile.Has(key) {
+			tree.Set(key, profile.Get(key))
+		}
+	}
+}
+
+// profile returns the settings of the named profile, or nil when there is none
+func (c *Config) profile(name string) *toml.Tree {
+	if c.profiles == nil {
+		return nil
+	}
+	profile, _ := c.profiles.GetPath([]string{name}).(*toml.Tree)
+	return profile
+}
+
+// unknownProfile is the error for choosing a profile that doesn't exist; it lists the ones that do
+func (c *Config) unknownProfile(name string) error {
+	names := c.profileNames()
+	if len(names) == 0 {
+		return fmt.Errorf("no profile named %q; there are no profiles yet", name)
+	}
+	return fmt.Errorf("no profile named %q; the profiles are %s", name, strings.Join(names, ", "))
+}
+
+func (c *Config) profileNames() []string {
+	if c.profiles == nil {
+		return nil
+	}
+	names := c.profiles.Keys()
+	sort.Strings(names)
+	return names
+}
+
+// addProfile saves a new profile with the current settings and switches to it
+func (c *Config) addProfile(name string) error {
+	if !profileNamePattern.MatchString(name) {
+		return fmt.Errorf("%q is not a valid profile name; use letters, digits, - and _", name)
+	}
+	for _, p := range c.profileNames() {
+		if p == name {
+			return fmt.Errorf("profile %q already exists", name)
+		}
+	}
+
+	settings, err := c.settingsTree()
+	if err != nil {
+		return err
+	}
+
+	if c.profiles == nil {
+		c.profiles, _ = toml.TreeFromMap(map[string]interface{}{})
+	}
+	profile, _ := toml.TreeFromMap(map[string]interface{}{})
+	c.profiles.SetPath([]string{name}, profile)
+	for _, key := range profileKeys {
+		setOrDelete(profile, key, settings.Get(key))
+	}
+
+	return c.switchProfile(name)
+}
+
+// switchProfile saves name as the selected profile and reloads the settings; "" selects no profile
+func (c *Config) switchProfile(name string) error {
+	c.writeConfigToFile()
+
+	tree, err := toml.LoadFile(c.path)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		tree.Delete("Profile")
+	} else {
+		tree.Set("Profile", name)
+	}
+	if c.profiles != nil {
+		tree.Set("Profiles", c.profiles)
+	}
+	if err := writeTree(c.path, tree); err != nil {
+		return err
+	}
+
+	c.reload()
+	return nil
+}
+
+// reload reads the config file again, keeping the runtime state
+func (c *Config) reload() {
+	fresh := Config{path: c.path}
+	fresh.readConfigFromFile()
+	fresh.HttpClient = c.HttpClient
+	fresh.Progress = c.Progress
+	fresh.DebugMode = c.DebugMode
+	*c = fresh
+}
+
+func (c *Config) setFileValue(key string, value interface{}) {
+	if c.fileValues == nil {
+		c.fileValues = make(map[string]interface{})
+	}
+	c.fileValues[key] = value
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/meeting"
+	"meeting-media/playlist"
+)
+
+// reviewGUI shows the fetched items in a window of their own, where they can be unticked,
+// reordered and renamed, and songs can be added. save is called with the result, and the window
+// stays open when it fails; closing the window saves nothing. job is held while the window is open.
+func (c *Config) reviewGUI(title string, items []playlist.Item, job *guiJob, save func([]playlist.Item) error) {
+	w := fyne.CurrentApp().NewWindow(title)
+	w.Resize(fyne.NewSize(600, 600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := job.hold()
+	w.SetOnClosed(func() {
+		cancel()
+		release()
+	})
+	thumbs := &thumbnails{c: c, ctx: ctx, loaded: make(map[string]fyne.Resource)}
+
+	status := widget.NewLabel("")
+	status.Wrapping = fyne.TextWrapWord
+
+	list := container.NewVBox()
+	var names []*widget.Entry
+	var rows func()
+	rows = func() {
+		list.Objects = nil
+		names = nil
+		for i := range items {
+			row, name := reviewRow(items, i, thumbs, rows)
+			list.Add(row)
+			names = append(names, name)
+		}
+		list.Refresh()
+	}
+	rows()
+
+	// songs are fetched as a job, so they can be cancelled and their download is shown
+	song := c.newSongBox("Song number")
+	addButton := widget.NewButton("Add song", func() {
+		num, err := meeting.ParseSongNumber(song.entry.Text)
+		if err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		status.SetText("fetching " + meeting.SongLabel(num, ""))
+		var it playlist.Item
+		err = job.start(func(jobCtx context.Context) (err error) {
+			c.Progress.Reset(1)
+			it, err = c.fetcher().Song(jobCtx, num)
+			return
+		}, func(err error) {
+			if ctx.Err() != nil {
+				return // the window was closed meanwhile
+			}
+			if err != nil {
+				status.SetText(err.Error())
+				return
+			}
+			items = append(items, it)
+			song.entry.SetText("")
+			status.SetText("added " + it.Title)
+			rows()
+		})
+		if err != nil {
+			status.SetText(err.Error())
+		}
+	})
+
+	saveButton := widget.NewButton("Save", func() {
+		for i, name := range names {
+			if err := name.Validate(); err != nil {
+				status.SetText(items[i].Label() + ": " + err.Error())
+				return
+			}
+		}
+		if err := playlist.Check(items); err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		if err := save(items); err != nil {
+			status.SetText(err.Error())
+			return
+		}
+		w.Close()
+	})
+	discardButton := widget.NewButton("Discard", func() {
+		logrus.Info("nothing saved")
+		w.Close()
+	})
+
+	add := container.NewBorder(nil, nil, nil, addButton, container.NewGridWithColumns(2, song.entry, song.title))
+	bottom := container.NewVBox(add, status, container.NewGridWithColumns(2, discardButton, saveButton))
+	w.SetContent(container.NewBorder(nil, bottom, nil, nil, container.NewVScroll(list)))
+	w.Show()
+}
+
+// reviewRow shows items[i], and returns the entry of its name; moving it calls rows to redraw the list
+func reviewRow(items []playlist.Item, i int, thumbs *thumbnails, rows func()) (fyne.CanvasObject, *widget.Entry) {
+	it := &items[i]
+
+	include := widget.NewCheck("", func(b bool) {
+		it.Include = b
+	})
+	include.SetChecked(it.Include)
+
+	thumb := canvas.NewImageFromResource(nil)
+	thumb.FillMode = canvas.ImageFillContain
+	thumb.SetMinSize(fyne.NewSize(64, 36))
+	thumbs.show(*it, thumb)
+
+	name := widget.NewEntry()
+	name.SetText(it.Name)
+	name.Validator = func(text string) error {
+		_, err := playlist.FileName(text, it.Source.Name)
+		return err
+	}
+	name.OnChanged = func(text string) {
+		// a name that isn't valid is kept in the entry, and stops saving, but is not used
+		if _, err := playlist.FileName(text, it.Source.Name); err == nil {
+			it.Rename(text)
+		}
+	}
+
+	title := widget.NewLabel(it.Kind + ": " + it.Label())
+	title.Wrapping = fyne.TextWrapWord
+
+	up := widget.NewButtonWithIcon("", theme.MoveUpIcon(), func() {
+		items[i-1], items[i] = items[i], items[i-1]
+		rows()
+	})
+	if i == 0 {
+		up.Disable()
+	}
+	down := widget.NewButtonWithIcon("", theme.MoveDownIcon(), func() {
+		items[i], items[i+1] = items[i+1], items[i]
+		rows()
+	})
+	if i == len(items)-1 {
+		down.Disable()
+	}
+
+	return container.NewBorder(nil, nil,
+		container.NewHBox(include, thumb),
+		container.NewHBox(up, down),
+		container.NewVBox(name, title),
+	), name
+}
+
+// thumbnails loads previews in the background, once each, so redrawing the list stays quick
+type thumbnails struct {
+	c   *Config
+	ctx context.Context
+
+	mu     sync.Mutex
+	loaded map[string]fyne.Resource
+}
+
+func (t *thumbnails) show(it playlist.Item, img *canvas.Image) {
+	key := it.Source.Name
+	t.mu.Lock()
+	res, ok := t.loaded[key]
+	t.mu.Unlock()
+	if ok {
+		img.Resource = res
+		return
+	}
+
+	go func() {
+		data, err := t.c.thumbnail(t.ctx, it)
+		if err != nil {
+			logrus.Debug(err)
+			return
+		}
+		res := fyne.NewStaticResource(key, data)
+		t.mu.Lock()
+		t.loaded[key] = res
+		t.mu.Unlock()
+
+		img.Resource = res
+		img.Refresh()
+	}()
+}
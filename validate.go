@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	languagePattern  = regexp.MustCompile(`^[A-Z][A-Z0-9]{0,5}$`)
+	pubSymbolPattern = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+)
+
+// fieldError is a problem with a single setting
+type fieldError struct {
+	Field string
+	Err   error
+}
+
+type configErrors []fieldError
+
+func (errs configErrors) Error() string {
+	var msgs []string
+	for _, e := range errs {
+		msgs = append(msgs, e.Field+": "+e.Err.Error())
+	}
+	return "invalid settings: " + strings.Join(msgs, "; ")
+}
+
+// validate checks every setting, and returns nothing when they can all be used for a fetch
+func (c *Config) validate() (errs configErrors) {
+	errs = append(errs, c.loadErrors...)
+
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fieldError{field, err})
+		}
+	}
+
+	check("SaveLocation", validateLocation(c.SaveLocation))
+	check("CacheLocation", validateLocation(c.CacheLocation))
+	if c.SaveLocation != "" && filepath.Clean(c.SaveLocation) == filepath.Clean(c.CacheLocation) {
+		check("CacheLocation", errors.New("must not be the same folder as the download path"))
+	}
+	if c.PublicationFolder != "" {
+		check("PublicationFolder", validateLocation(c.PublicationFolder))
+	}
+	check("Proxy", validateProxy(c.Proxy))
+	for i, path := range c.CACertificates {
+		check(fmt.Sprintf("CACertificates[%d]", i), validateCertificateFile(path))
+	}
+	check("RequestsPerMinute", validateNotNegative(c.RequestsPerMinute))
+	check("ConnectTimeout", validateDuration(c.ConnectTimeout))
+	check("Retries", validateNotNegative(c.Retries))
+	check("RetryWaitMin", validateDuration(c.RetryWaitMin))
+	check("RetryWaitMax", validateDuration(c.RetryWaitMax))
+	if min, err := time.ParseDuration(c.RetryWaitMin); err == nil {
+		if max, err := time.ParseDuration(c.RetryWaitMax); err == nil && max < min {
+			check("RetryWaitMax", errors.New("must not be shorter than RetryWaitMin"))
+		}
+	}
+	check("Resolution", validateResolution(c.Resolution))
+	check("OutputMode", validateOutputMode(c.OutputMode))
+	check("Language", validateLanguage(c.Language))
+	check("PubSymbols", validatePubSymbols(c.PubSymbols))
+	for i, e := range c.Exclusions {
+		check(fmt.Sprintf("Exclusions[%d]", i), e.Validate())
+	}
+	for i, hook := range c.PreFetchHooks {
+		check(fmt.Sprintf("PreFetchHooks[%d]", i), validateHook(hook))
+	}
+	for i, hook := range c.PostFetchHooks {
+		check(fmt.Sprintf("PostFetchHooks[%d]", i), validateHook(hook))
+	}
+
+	return
+}
+
+func validateLocation(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return errors.New("a folder is required")
+	}
+	if !filepath.IsAbs(path) {
+		return errors.New("must be an absolute path")
+	}
+	if info, err := os.Stat(path); err == nil && !info.IsDir() {
+		return errors.New("is not a folder")
+	}
+	return nil
+}
+
+func validateResolution(res string) error {
+	switch res {
+	case RES240, RES360, RES480, RES720, AUDIO:
+		return nil
+	}
+	return fmt.Errorf("unknown resolution %q", res)
+}
+
+func validateOutputMode(mode string) error {
+	switch mode {
+	case SYMLINK, HARDLINK, COPY:
+		return nil
+	}
+	return fmt.Errorf("unknown output mode %q", mode)
+}
+
+func validateLanguage(lang string) error {
+	if !languagePattern.MatchString(lang) {
+		return fmt.Errorf("%q is not a MEPS language symbol (eg. E)", lang)
+	}
+	return nil
+}
+
+func validatePubSymbols(symbols []string) error {
+	seen := make(map[string]bool)
+	for _, s := range symbols {
+		if !pubSymbolPattern.MatchString(s) {
+			return fmt.Errorf("%q is not a publication symbol (eg. th)", s)
+		}
+		if seen[s] {
+			return fmt.Errorf("%q is listed twice", s)
+		}
+		seen[s] = true
+	}
+	return nil
+}
+
+// parseLines returns the lines of text that aren't blank, trimmed
+func parseLines(text string) (lines []string) {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return
+}
+
+// parsePubSymbols turns the comma separated list from the settings into symbols
+func parsePubSymbols(text string) (symbols []string) {
+	for _, p := range strings.Split(text, ",") {
+		if p = strings.TrimSpace(strings.ToLower(p)); p != "" {
+			symbols = append(symbols, p)
+		}
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/sirupsen/logrus"
+
+	"meeting-media/cache"
+)
+
+type verifyResult struct {
+	Path    string
+	Problem string
+	Fixed   bool
+}
+
+// verify rechecks CacheLocation and SaveLocation against their checksum indexes.
+// With refetch set, corrupt or missing cache files are downloaded again and
+// broken links in SaveLocation are recreated.
+func (c *Config) verify(ctx context.Context, refetch bool) ([]verifyResult, error) {
+	cacheIndex, err := cache.ReadIndex(c.CacheLocation)
+	if err != nil {
+		return nil, err
+	}
+	saveIndex, err := cache.ReadIndex(c.SaveLocation)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []verifyResult
+
+	for _, name := range cache.SortedNames(cacheIndex) {
+		path := filepath.Join(c.CacheLocation, name)
+		problem := cache.CheckFile(path, cacheIndex[name].Checksum)
+		if problem == "" {
+			continue
+		}
+
+		r := verifyResult{Path: path, Problem: problem}
+		if refetch {
+			r.Fixed = fixed(c.mediaCache().Refetch(ctx, name, cacheIndex[name]))
+		}
+		results = append(results, r)
+	}
+
+	entries, err := os.ReadDir(c.SaveLocation)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		path := filepath.Join(c.SaveLocation, name)
+		cached, inCache := cacheIndex[name]
+
+		if e.Type()&os.ModeSymlink != 0 {
+			if _, err := os.Stat(path); err == nil {
+				continue
+			}
+
+			r := verifyResult{Path: path, Problem: "broken link"}
+			if refetch && inCache {
+				r.Fixed = fixed(c.relink(ctx, name, cached))
+			}
+			results = append(results, r)
+			continue
+		}
+
+		entry, ok := saveIndex[name]
+		if !ok {
+			entry, ok = cached, inCache
+		}
+		if !ok {
+			continue
+		}
+
+		if problem := cache.CheckFile(path, entry.Checksum); problem != "" {
+			r := verifyResult{Path: path, Problem: problem}
+			if refetch && inCache {
+				r.Fixed = fixed(c.relink(ctx, name, cached))
+			}
+			results = append(results, r)
+		}
+	}
+
+	for _, name := range cache.SortedNames(saveIndex) {
+		path := filepath.Join(c.SaveLocation, name)
+		if _, err := os.Lstat(path); os.IsNotExist(err) {
+			results = append(results, verifyResult{Path: path, Problem: "missing"})
+		}
+	}
+
+	return results, nil
+}
+
+// relink makes sure the cached copy of name is intact and links it into SaveLocation again
+func (c *Config) relink(ctx context.Context, name string, entry cache.IndexEntry) error {
+	if cache.CheckFile(filepath.Join(c.CacheLocation, name), entry.Checksum) != "" {
+		if err := c.mediaCache().Refetch(ctx, name, entry); err != nil {
+			return err
+		}
+	}
+
+	return c.saver().Link(name)
+}
+
+func fixed(err error) bool {
+	if err != nil {
+		logrus.Warn(err)
+		return false
+	}
+	return true
+}